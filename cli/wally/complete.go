@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/nylar/wally"
+)
+
+func CompleteCommand() cli.Command {
+	return cli.Command{
+		Name:  "complete",
+		Usage: "list the indexed words a prefix could be completed as, e.g. --prefix 'hank pro'",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "prefix",
+				Value: "",
+				Usage: "text to complete",
+			},
+			cli.IntFlag{
+				Name:  "n",
+				Value: 0,
+				Usage: "number of completions, defaults to the config's autocomplete size",
+			},
+		},
+		Action: func(c *cli.Context) {
+			CompleteFunc(c)
+		},
+	}
+}
+
+func CompleteFunc(c *cli.Context) {
+	prefix := c.String("prefix")
+	if prefix == "" {
+		prefix = strings.Join(c.Args(), " ")
+	}
+
+	completions, err := wally.Complete(prefix, c.Int("n"), store)
+	if err != nil {
+		logError(err)
+	}
+
+	if len(completions.Terms) == 0 && len(completions.Queries) == 0 {
+		wally.Warning.Println("No completions found")
+		return
+	}
+	for _, t := range completions.Terms {
+		wally.Success.Printf("%s (%d)\n", t.Word, t.Frequency)
+	}
+	for _, q := range completions.Queries {
+		wally.Info.Printf("%s (%d)\n", q.Word, q.Frequency)
+	}
+}
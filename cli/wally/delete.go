@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/nylar/wally"
+
+	"github.com/codegangsta/cli"
+)
+
+func DeleteCommand() cli.Command {
+	return cli.Command{
+		Name:  "delete",
+		Usage: "deletes documents and their indexes",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "url",
+				Value: "",
+				Usage: "deletes url",
+			},
+			cli.StringFlag{
+				Name:  "prefix",
+				Value: "",
+				Usage: "deletes every url starting with prefix, e.g. a whole site",
+			},
+		},
+		Action: func(c *cli.Context) {
+			DeleteFunc(c)
+		},
+	}
+}
+
+func DeleteFunc(c *cli.Context) {
+	url, prefix := c.String("url"), c.String("prefix")
+	if url == "" && prefix == "" {
+		logError(errors.New("delete needs a --url or a --prefix"))
+	}
+
+	if url != "" {
+		for _, u := range strings.Split(url, "|") {
+			if err := store.DeleteDocument(u); err != nil {
+				logError(err)
+			}
+			wally.Success.Printf("Deleted %s\n", u)
+		}
+	}
+
+	if prefix != "" {
+		n, err := store.DeletePrefix(prefix)
+		if err != nil {
+			logError(err)
+		}
+		wally.Success.Printf("Deleted %d documents\n", n)
+	}
+}
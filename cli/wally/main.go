@@ -1,6 +1,7 @@
 package main
 
 import (
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -8,13 +9,12 @@ import (
 	"github.com/nylar/wally"
 
 	"github.com/codegangsta/cli"
-	rdb "github.com/dancannon/gorethink"
 	"github.com/fatih/color"
 )
 
 var (
 	version = "0.1"
-	session *rdb.Session
+	store   wally.Store
 )
 
 func logError(err error) {
@@ -35,10 +35,7 @@ func init() {
 		logError(err)
 	}
 
-	session, err = rdb.Connect(rdb.ConnectOpts{
-		Address:  wally.Conf.Database.Host,
-		Database: "test",
-	})
+	store, err = wally.OpenStore(wally.Conf)
 	if err != nil {
 		logError(err)
 	}
@@ -68,7 +65,15 @@ func main() {
 		CrawlCommand(),
 		RebuildCommand(),
 		SearchCommand(),
+		CompleteCommand(),
+		DeleteCommand(),
 	}
 
 	app.Run(os.Args)
+
+	if c, ok := store.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			logError(err)
+		}
+	}
 }
@@ -17,6 +17,8 @@ func RebuildCommand() cli.Command {
 }
 
 func RebuildFunc() {
-	wally.DatabaseRebuild(session)
+	if err := wally.DatabaseRebuild(store); err != nil {
+		logError(err)
+	}
 	wally.Success.Println("Rebuilt database")
 }
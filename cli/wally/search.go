@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/codegangsta/cli"
 	"github.com/nylar/wally"
@@ -10,13 +11,18 @@ import (
 func SearchCommand() cli.Command {
 	return cli.Command{
 		Name:  "search",
-		Usage: "search wally",
+		Usage: `search wally, e.g. --query '"king hill" hank NEAR/5 propan*'`,
 		Flags: []cli.Flag{
 			cli.StringFlag{
 				Name:  "query",
 				Value: "",
 				Usage: "query args",
 			},
+			cli.IntFlag{
+				Name:  "near",
+				Value: 0,
+				Usage: "only find the query's two words within this many words of each other",
+			},
 		},
 		Action: func(c *cli.Context) {
 			SearchFunc(c)
@@ -26,28 +32,55 @@ func SearchCommand() cli.Command {
 
 func SearchFunc(c *cli.Context) {
 	query := c.String("query")
+	if query == "" {
+		query = strings.Join(c.Args(), " ")
+	}
+
+	// --near joins the query's two words with NEAR/n, NEAR/n only takes a
+	// word either side so anything else is an error
+	if n := c.Int("near"); n > 0 {
+		words := strings.Fields(query)
+		if len(words) != 2 || strings.ContainsAny(query, `"()`) {
+			logError(fmt.Errorf("--near needs a query of two words, e.g. --near 5 hank propane"))
+		}
+		query = fmt.Sprintf("%s NEAR/%d %s", words[0], n, words[1])
+	}
 
-	results, err := wally.Search(query, session, 1)
+	results, err := wally.Search(query, store, 1)
 	if err != nil {
 		logError(err)
 	}
 
 	if results.Count == 0 {
 		fmt.Println("No results found")
+		if results.Suggestion != "" {
+			wally.Warning.Printf("Did you mean: %s\n", results.Suggestion)
+			fmt.Printf("  wally search --query %s\n", shellQuote(results.Suggestion))
+		}
 	} else {
-		wally.Std.Printf("\nFound %d results in %s\n\n", results.Count, results.Time)
+		wally.Std.Printf("\nFound %d results in %fs\n\n", results.Count, results.Time)
 		for _, r := range results.Results {
-			content := r.Content
 			if r.Title != "" {
 				wally.Info.Printf("\n%s", r.Title)
-				wally.Success.Printf("\n%s\n", r.Document.ID)
+				wally.Success.Printf("\n%s (%.2f)\n", r.Document.ID, r.Score)
 			} else {
-				wally.Success.Printf("\n%s\n", r.Document.ID)
+				wally.Success.Printf("\n%s (%.2f)\n", r.Document.ID, r.Score)
+			}
+			matched := []string{}
+			for _, index := range r.Indexes {
+				if index.Surface != "" {
+					matched = append(matched, index.Surface)
+				}
 			}
-			if len(r.Content) > 150 {
-				content = r.Content[:150] + " ..."
+			if len(matched) > 0 {
+				wally.Warning.Printf("matched %s\n", strings.Join(matched, ", "))
 			}
-			fmt.Printf("%s\n\n", content)
+			fmt.Printf("%s\n\n", r.Snippet)
 		}
 	}
 }
+
+// shellQuote quotes s so it can be pasted into a shell as a single argument.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
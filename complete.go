@@ -0,0 +1,135 @@
+package wally
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Completion is a word or query that carries on from what's been typed into a
+// search box, Frequency is the number of documents an indexed word is found in
+// or the number of times a query has been searched for.
+type Completion struct {
+	Word      string
+	Frequency int64
+}
+
+// Completions holds the completions for a prefix, Terms are indexed words and
+// Queries past queries, each ordered most frequent first.
+type Completions struct {
+	Terms   []Completion
+	Queries []Completion
+}
+
+// Complete returns the n indexed terms the last word of prefix could be
+// completed as, those found in the most documents first, so prefix "hank pro"
+// gives terms like "propan". Terms come from a document's content unless the
+// word names a field, "title:ki" completes words of titles, fields indexed as
+// n-grams aren't completed. When surface forms are kept content terms are given
+// as they're most often written. When Autocomplete.Queries is set the n most
+// popular past queries starting with prefix are given as well.
+// Autocomplete.Size is used when n isn't positive.
+func Complete(prefix string, n int, store Store) (*Completions, error) {
+	if n <= 0 {
+		n = config().Autocomplete.Size
+	}
+
+	c := &Completions{Terms: []Completion{}, Queries: []Completion{}}
+
+	if config().Autocomplete.Queries {
+		c.Queries = pastQueries.top(prefix, n)
+	}
+
+	// Nothing is left to complete once the last word has been finished
+	words := strings.Fields(prefix)
+	if len(words) == 0 {
+		return c, nil
+	}
+	if r, _ := utf8.DecodeLastRuneInString(prefix); unicode.IsSpace(r) {
+		return c, nil
+	}
+
+	field, word := "", words[len(words)-1]
+	if i := strings.Index(word, ":"); i > 0 && isField(word[:i]) {
+		field, word = strings.ToLower(word[:i]), word[i+1:]
+	}
+
+	// The terms of a field indexed as n-grams aren't words
+	if isNGramField(field) || (field == "" && isNGramField(ContentField)) {
+		return c, nil
+	}
+
+	word = FoldCase(word)
+	if config().Analysis.FoldDiacritics {
+		word = FoldDiacritics(word)
+	}
+	if word == "" {
+		return c, nil
+	}
+
+	name := fieldTerm(field, "")
+	terms, err := store.Completions(name+word, n)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range terms {
+		t.Word = strings.TrimPrefix(t.Word, name)
+		if field == "" {
+			if t.Word, err = surfaceWord(t.Word, store); err != nil {
+				return nil, err
+			}
+		}
+		c.Terms = append(c.Terms, t)
+	}
+	return c, nil
+}
+
+// pastQueries holds the queries that have found something when
+// Autocomplete.Queries is set.
+var pastQueries = newQueryLog()
+
+// queryLog counts how often each query is searched for, queries are matched
+// ignoring case and extra whitespace and given as they were last written.
+type queryLog struct {
+	mu      sync.Mutex
+	queries *trie
+	written map[string]string
+}
+
+func newQueryLog() *queryLog {
+	return &queryLog{queries: newTrie(), written: map[string]string{}}
+}
+
+// record counts a search for query.
+func (l *queryLog) record(query string) {
+	query = strings.Join(strings.Fields(query), " ")
+	if query == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := FoldCase(query)
+	l.queries.insert(key)
+	l.written[key] = query
+}
+
+// top returns the n queries starting with prefix searched for most often.
+func (l *queryLog) top(prefix string, n int) []Completion {
+	key := FoldCase(strings.Join(strings.Fields(prefix), " "))
+	if r, _ := utf8.DecodeLastRuneInString(prefix); key != "" && unicode.IsSpace(r) {
+		key += " "
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	queries := l.queries.top(key, n, true)
+	for i := range queries {
+		queries[i].Word = l.written[queries[i].Word]
+	}
+	return queries
+}
@@ -0,0 +1,88 @@
+package wally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComplete_Complete(t *testing.T) {
+	defer tearDbDown(store)
+
+	docs := []*Document{
+		{ID: "1", Title: "Propane", Content: "Hank sells propane and propane accessories"},
+		{ID: "2", Content: "Hank sells propane"},
+		{ID: "3", Content: "Hank drinks beer with the professor"},
+	}
+	for _, doc := range docs {
+		assert.NoError(t, IndexBatchPut(store, IndexDocument(doc)))
+	}
+
+	tests := []struct {
+		Prefix string
+		N      int
+		Terms  []Completion
+	}{
+		{"pro", 0, []Completion{{"propan", 2}, {"professor", 1}}},
+		{"Pro", 1, []Completion{{"propan", 2}}},
+		{"hank pro", 0, []Completion{{"propan", 2}, {"professor", 1}}},
+		{"title:pro", 0, []Completion{{"propan", 1}}},
+		{"author:pro", 0, []Completion{}},
+		{"ti", 0, []Completion{}},
+		{"hank ", 0, []Completion{}},
+		{"", 0, []Completion{}},
+	}
+
+	for _, test := range tests {
+		c, err := Complete(test.Prefix, test.N, store)
+		assert.NoError(t, err, test.Prefix)
+		assert.Equal(t, test.Terms, c.Terms, test.Prefix)
+		assert.Equal(t, []Completion{}, c.Queries, test.Prefix)
+	}
+}
+
+func TestComplete_CompleteSurface(t *testing.T) {
+	defer tearDbDown(store)
+
+	Conf.Analysis.KeepSurface = true
+	defer func() { Conf.Analysis.KeepSurface = false }()
+
+	doc := &Document{ID: "1", Content: "Hank sells propane and propane accessories"}
+	assert.NoError(t, IndexBatchPut(store, IndexDocument(doc)))
+
+	c, err := Complete("prop", 0, store)
+	assert.NoError(t, err)
+	assert.Equal(t, []Completion{{"propane", 1}}, c.Terms)
+}
+
+func TestComplete_CompleteQueries(t *testing.T) {
+	defer tearDbDown(store)
+
+	Conf.Autocomplete.Queries = true
+	defer func() {
+		Conf.Autocomplete.Queries = false
+		pastQueries = newQueryLog()
+	}()
+
+	putDocuments(t, []*Document{{ID: "1", Title: "Hank Hill", Content: "Hank sells propane and propane accessories"}})
+
+	// Queries that don't find anything aren't remembered
+	for _, query := range []string{"hank", "Hank  propane", "hank propane", "hank AND beer", "title:hank"} {
+		_, err := Search(query, store, 1)
+		assert.NoError(t, err)
+	}
+
+	c, err := Complete("HANK", 0, store)
+	assert.NoError(t, err)
+	assert.Equal(t, []Completion{{"hank propane", 2}, {"hank", 1}}, c.Queries)
+	assert.Equal(t, []Completion{{"hank", 1}}, c.Terms)
+
+	c, err = Complete("hank ", 0, store)
+	assert.NoError(t, err)
+	assert.Equal(t, []Completion{{"hank propane", 2}}, c.Queries)
+	assert.Equal(t, []Completion{}, c.Terms)
+
+	c, err = Complete("title:", 0, store)
+	assert.NoError(t, err)
+	assert.Equal(t, []Completion{{"title:hank", 1}}, c.Queries)
+}
@@ -1,28 +1,305 @@
 package wally
 
-import "gopkg.in/yaml.v2"
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
 
 type Config struct {
-	Database Db     `yaml:"database"`
-	Tables   Tables `yaml:"tables"`
+	Storage      Storage      `yaml:"storage"`
+	Database     Db           `yaml:"database"`
+	Tables       Tables       `yaml:"tables"`
+	Analysis     Analysis     `yaml:"analysis"`
+	Scoring      Scoring      `yaml:"scoring"`
+	Highlight    Highlight    `yaml:"highlight"`
+	Expansion    Expansion    `yaml:"expansion"`
+	Autocomplete Autocomplete `yaml:"autocomplete"`
+	Synonyms     Synonyms     `yaml:"synonyms"`
+}
+
+// Storage selects the backend used to store documents and indexes, Engine is
+// one of rethinkdb, disk or memory and defaults to rethinkdb. Path is the
+// directory used by the disk engine.
+type Storage struct {
+	Engine string `yaml:"engine"`
+	Path   string `yaml:"path"`
+}
+
+// Scoring selects how search results are ranked, Model is bm25 (the default)
+// or tfidf. K1 and B tune BM25, K1 controls how quickly repeating a term stops
+// adding to a document's score and B how much long documents are penalised.
+// Boosts multiplies the score of matches in each document field, by default
+// matches in a title count double and matches of an author half as much again.
+type Scoring struct {
+	Model  string             `yaml:"model"`
+	K1     float64            `yaml:"k1"`
+	B      float64            `yaml:"b"`
+	Boosts map[string]float64 `yaml:"boosts"`
+}
+
+// Highlight controls the snippets of text shown with search results, Length is
+// the most bytes of a document's content a snippet holds, DefaultSnippetLength
+// when it isn't positive, and matched words are wrapped in PreTag and PostTag.
+// The tags default to <b> and </b> and the text around them is escaped for
+// HTML, an ANSI escape sequence can be used to colour matches in a terminal
+// instead, with EscapeHTML turned off.
+type Highlight struct {
+	PreTag     string `yaml:"pre_tag"`
+	PostTag    string `yaml:"post_tag"`
+	Length     int    `yaml:"length"`
+	EscapeHTML bool   `yaml:"escape_html"`
+}
+
+// Expansion limits how far a word of a query is expanded, MaxTerms is the most
+// indexed terms a wildcard pattern such as propan* or a fuzzy word such as
+// rutherfrod~2 is searched for as, the shortest or closest terms are kept. A
+// MaxTerms of 0 means there's no limit. MaxEdits is the most edits a fuzzy
+// word may be allowed, larger distances in a query are reduced to it.
+type Expansion struct {
+	MaxTerms int `yaml:"max_terms"`
+	MaxEdits int `yaml:"max_edits"`
+}
+
+// Autocomplete controls the completions Complete gives for what's been typed
+// into a search box, Size is how many terms and past queries are given when
+// the caller doesn't ask for a number. Queries remembers the queries that found
+// something so the most popular can be offered too, it's off by default and
+// the queries are only kept in memory.
+type Autocomplete struct {
+	Size    int  `yaml:"size"`
+	Queries bool `yaml:"queries"`
 }
 
 type Db struct {
 	Host string `yaml:"host"`
-	Name string `yaml:"name`
+	Name string `yaml:"name"`
 }
 
+// Tables names the RethinkDB tables, TermTable holds each distinct indexed
+// word and the number of documents it's found in, LengthTable the number of
+// terms in each document and StatsTable the totals across them. They're kept
+// up to date as indexes are written so searches don't have to count them.
 type Tables struct {
 	DocumentTable string `yaml:"document_table"`
 	IndexTable    string `yaml:"index_table"`
+	TermTable     string `yaml:"term_table"`
+	LengthTable   string `yaml:"length_table"`
+	StatsTable    string `yaml:"stats_table"`
+}
+
+// Analysis controls how text is turned into terms, the same settings are used
+// when indexing documents and when parsing search queries. Language is the
+// default language of documents and queries, defaulting to English, and
+// Languages lists any other languages documents are written in so queries are
+// analysed in those as well. Stemming is on by default, KeepSurface stores the
+// first form of each word seen in a document alongside its stem so it can be
+// shown to users. DetectLanguage guesses the language of crawled pages that
+// don't declare one, it's on by default. FoldDiacritics removes accents from
+// terms so "cafe" matches "café". StopWords adds to or replaces the default
+// language's stop words. NGrams lists fields indexed as n-grams rather than
+// words.
+type Analysis struct {
+	Language       string    `yaml:"language"`
+	Languages      []string  `yaml:"languages"`
+	Stemming       bool      `yaml:"stemming"`
+	KeepSurface    bool      `yaml:"keep_surface"`
+	DetectLanguage bool      `yaml:"detect_language"`
+	FoldDiacritics bool      `yaml:"fold_diacritics"`
+	StopWords      StopWords `yaml:"stop_words"`
+	NGrams         NGrams    `yaml:"ngrams"`
+}
+
+// NGrams indexes the listed Fields, content, title or author, as overlapping
+// runs of Size characters instead of words, so a search finds words in the
+// middle of others and text written without spaces, like Chinese or Japanese,
+// can be searched. Size defaults to 3, 2 suits Chinese and Japanese. The text
+// of these fields is case folded but isn't stemmed and stop words are kept.
+type NGrams struct {
+	Fields []string `yaml:"fields"`
+	Size   int      `yaml:"size"`
+}
+
+// StopWords configures the stop words used for the default language, words are
+// read from File, one per line with blank lines and lines starting with # being
+// ignored, and added to those listed in Words. They're added to the built in
+// list unless Replace is set, when they're used in its place so a word can be
+// brought back by leaving it out. Disabled turns off stop words for every
+// language.
+type StopWords struct {
+	Disabled bool     `yaml:"disabled"`
+	Replace  bool     `yaml:"replace"`
+	File     string   `yaml:"file"`
+	Words    []string `yaml:"words"`
+
+	set map[string]bool
+}
+
+// Load reads the stop words from File and Words, LoadConfig calls it and it has
+// to be called again if either is changed afterwards.
+func (s *StopWords) Load() error {
+	s.set = nil
+	if s.File == "" && len(s.Words) == 0 {
+		return nil
+	}
+
+	set := map[string]bool{}
+	for _, word := range s.Words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			set[word] = true
+		}
+	}
+
+	if s.File != "" {
+		data, err := ioutil.ReadFile(s.File)
+		if err != nil {
+			return err
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+			if word == "" || strings.HasPrefix(word, "#") {
+				continue
+			}
+			set[word] = true
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	s.set = set
+	return nil
+}
+
+// Synonyms configures the synonyms words of a query are expanded with, rules
+// are read from File, one per line with blank lines and lines starting with #
+// being ignored, and added to those listed in Rules. A rule is either a group
+// of equivalent words and phrases separated by commas, "propane, lpg", or
+// one-way synonyms such as "lp gas => propane" where the words on the left also
+// match those on the right but not the other way round. Synonyms are matched
+// ignoring case but aren't stemmed.
+type Synonyms struct {
+	File  string   `yaml:"file"`
+	Rules []string `yaml:"rules"`
+
+	set *synonymSet
+}
+
+// Load reads the rules from File and Rules, LoadConfig calls it and it has to
+// be called again if either is changed afterwards.
+func (s *Synonyms) Load() error {
+	s.set = nil
+	if s.File == "" && len(s.Rules) == 0 {
+		return nil
+	}
+
+	set := newSynonymSet()
+	for i, rule := range s.Rules {
+		if err := set.add(rule); err != nil {
+			return fmt.Errorf("synonym rule %d: %s", i+1, err)
+		}
+	}
+
+	if s.File != "" {
+		data, err := ioutil.ReadFile(s.File)
+		if err != nil {
+			return err
+		}
+
+		line := 0
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line++
+			rule := strings.TrimSpace(scanner.Text())
+			if rule == "" || strings.HasPrefix(rule, "#") {
+				continue
+			}
+			if err := set.add(rule); err != nil {
+				return fmt.Errorf("%s:%d: %s", s.File, line, err)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	s.set = set
+	return nil
+}
+
+var defaultConfig = DefaultConfig()
+
+// DefaultConfig returns a Config holding wally's default settings, LoadConfig
+// starts from these defaults.
+func DefaultConfig() *Config {
+	return &Config{
+		Tables: Tables{
+			TermTable:   "terms",
+			LengthTable: "lengths",
+			StatsTable:  "stats",
+		},
+		Analysis: Analysis{
+			Language:       "en",
+			Stemming:       true,
+			DetectLanguage: true,
+			NGrams: NGrams{
+				Size: DefaultNGramSize,
+			},
+		},
+		Scoring: Scoring{
+			Model: BM25,
+			K1:    1.2,
+			B:     0.75,
+			Boosts: map[string]float64{
+				ContentField: 1,
+				TitleField:   2,
+				AuthorField:  1.5,
+			},
+		},
+		Highlight: Highlight{
+			PreTag:     "<b>",
+			PostTag:    "</b>",
+			Length:     DefaultSnippetLength,
+			EscapeHTML: true,
+		},
+		Expansion: Expansion{
+			MaxTerms: 50,
+			MaxEdits: 2,
+		},
+		Autocomplete: Autocomplete{
+			Size: 10,
+		},
+	}
+}
+
+// config returns Conf, or the defaults when no config has been loaded.
+func config() *Config {
+	if Conf == nil {
+		return defaultConfig
+	}
+	return Conf
 }
 
 func LoadConfig(file []byte) (*Config, error) {
-	c := new(Config)
+	c := DefaultConfig()
 
 	if err := yaml.Unmarshal(file, &c); err != nil {
 		return nil, err
 	}
 
+	if err := c.Analysis.StopWords.Load(); err != nil {
+		return nil, err
+	}
+
+	if err := c.Synonyms.Load(); err != nil {
+		return nil, err
+	}
+
 	return c, nil
 }
@@ -27,6 +27,58 @@ func TestConfig_LoadConfig(t *testing.T) {
 	assert.Equal(t, conf.Database.Name, "wally")
 	assert.Equal(t, conf.Tables.DocumentTable, "documents")
 	assert.Equal(t, conf.Tables.IndexTable, "indexes")
+	assert.Equal(t, conf.Tables.TermTable, "terms")
+	assert.Equal(t, conf.Tables.LengthTable, "lengths")
+	assert.Equal(t, conf.Tables.StatsTable, "stats")
+	assert.True(t, conf.Analysis.Stemming)
+	assert.True(t, conf.Analysis.DetectLanguage)
+	assert.Equal(t, conf.Scoring, Scoring{
+		Model:  BM25,
+		K1:     1.2,
+		B:      0.75,
+		Boosts: map[string]float64{"content": 1, "title": 2, "author": 1.5},
+	})
+}
+
+func TestConfig_LoadConfigScoring(t *testing.T) {
+	data := []byte(`
+scoring:
+  model: tfidf
+  b: 0.5
+  boosts:
+    title: 5
+`)
+	conf, err := LoadConfig(data)
+
+	assert.NoError(t, err)
+	assert.Equal(t, conf.Scoring, Scoring{
+		Model:  TFIDF,
+		K1:     1.2,
+		B:      0.5,
+		Boosts: map[string]float64{"content": 1, "title": 5, "author": 1.5},
+	})
+	assert.Equal(t, 5.0, conf.Scoring.boost("title"))
+	assert.Equal(t, 1.0, conf.Scoring.boost(""))
+	assert.Equal(t, 1.0, conf.Scoring.boost("url"))
+}
+
+func TestConfig_LoadConfigHighlight(t *testing.T) {
+	conf, err := LoadConfig([]byte(validConf))
+	assert.NoError(t, err)
+	assert.Equal(t, conf.Highlight, Highlight{PreTag: "<b>", PostTag: "</b>", Length: 150, EscapeHTML: true})
+	assert.Equal(t, conf.Expansion, Expansion{MaxTerms: 50, MaxEdits: 2})
+	assert.Equal(t, conf.Autocomplete, Autocomplete{Size: 10})
+
+	data := []byte(`
+highlight:
+  pre_tag: "\e[1m"
+  post_tag: "\e[0m"
+  escape_html: false
+`)
+	conf, err = LoadConfig(data)
+
+	assert.NoError(t, err)
+	assert.Equal(t, conf.Highlight, Highlight{PreTag: "\x1b[1m", PostTag: "\x1b[0m", Length: 150})
 }
 
 func TestConfig_LoadConfigBadYAML(t *testing.T) {
@@ -37,3 +89,102 @@ func TestConfig_LoadConfigBadYAML(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, conf)
 }
+
+func TestConfig_LoadConfigStorage(t *testing.T) {
+	data := []byte(`
+storage:
+  engine: disk
+  path: /var/lib/wally
+`)
+	conf, err := LoadConfig(data)
+
+	assert.NoError(t, err)
+	assert.Equal(t, conf.Storage.Engine, "disk")
+	assert.Equal(t, conf.Storage.Path, "/var/lib/wally")
+}
+
+func TestConfig_LoadConfigAnalysis(t *testing.T) {
+	data := []byte(`
+analysis:
+  stemming: false
+  keep_surface: true
+  detect_language: false
+  fold_diacritics: true
+  ngrams:
+    fields: [title]
+`)
+	conf, err := LoadConfig(data)
+
+	assert.NoError(t, err)
+	assert.Equal(t, NGrams{Fields: []string{"title"}, Size: 3}, conf.Analysis.NGrams)
+	assert.False(t, conf.Analysis.Stemming)
+	assert.True(t, conf.Analysis.KeepSurface)
+	assert.False(t, conf.Analysis.DetectLanguage)
+	assert.True(t, conf.Analysis.FoldDiacritics)
+}
+
+func TestConfig_LoadConfigStopWords(t *testing.T) {
+	data := []byte(`
+analysis:
+  stop_words:
+    file: test_data/stopwords.txt
+    words: [Beer, alley]
+    replace: true
+`)
+	conf, err := LoadConfig(data)
+
+	assert.NoError(t, err)
+	assert.True(t, conf.Analysis.StopWords.Replace)
+	assert.Equal(t, conf.Analysis.StopWords.set, map[string]bool{
+		"propane": true,
+		"a":       true,
+		"an":      true,
+		"beer":    true,
+		"alley":   true,
+	})
+}
+
+func TestConfig_LoadConfigStopWordsNoFile(t *testing.T) {
+	data := []byte(`
+analysis:
+  stop_words:
+    file: test_data/missing.txt
+`)
+	conf, err := LoadConfig(data)
+	assert.Error(t, err)
+	assert.Nil(t, conf)
+}
+
+func TestConfig_LoadConfigSynonyms(t *testing.T) {
+	data := []byte(`
+synonyms:
+  file: test_data/synonyms.txt
+  rules: ["beer, ale"]
+`)
+	conf, err := LoadConfig(data)
+
+	assert.NoError(t, err)
+	assert.Equal(t, conf.Synonyms.set.synonyms, map[string][]string{
+		"beer":             {"ale"},
+		"ale":              {"beer"},
+		"propane":          {"lpg"},
+		"lpg":              {"propane"},
+		"lp gas":           {"propane", "liquefied petroleum gas"},
+		"king of the hill": {"koth"},
+	})
+	assert.Equal(t, 4, conf.Synonyms.set.longest)
+
+	conf, err = LoadConfig([]byte(validConf))
+	assert.NoError(t, err)
+	assert.Nil(t, conf.Synonyms.set)
+}
+
+func TestConfig_LoadConfigSynonymsBadRule(t *testing.T) {
+	for _, rule := range []string{"beer", "beer =>", "=> beer", "a => b => c", ", ,"} {
+		_, err := LoadConfig([]byte("synonyms:\n  rules: [\"" + rule + "\"]\n"))
+		assert.Error(t, err, rule)
+	}
+
+	_, err := LoadConfig([]byte("synonyms:\n  file: test_data/missing.txt\n"))
+	assert.Error(t, err)
+}
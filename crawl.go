@@ -3,27 +3,67 @@ package wally
 import (
 	"io/ioutil"
 	"net/http"
+	"regexp"
+	"strings"
 
-	rdb "github.com/dancannon/gorethink"
 	"github.com/nylar/odlaw"
 )
 
-func grabURL(url string) ([]byte, error) {
+// htmlLangRegex matches the lang (or xml:lang) attribute of a page's <html>
+// element.
+var htmlLangRegex = regexp.MustCompile(`(?is)<html[^>]*\s(?:xml:)?lang\s*=\s*["']?([a-z]{2,3}(?:[-_][a-z0-9]+)*)`)
+
+func grabURL(url string) ([]byte, http.Header, error) {
 	resp, err := http.Get(url)
 	if err != nil {
-		return []byte{}, err
+		return []byte{}, nil, err
 	}
 
 	data, _ := ioutil.ReadAll(resp.Body)
 	defer resp.Body.Close()
 
-	return data, nil
+	return data, resp.Header, nil
+}
+
+// htmlLanguage returns the language declared by the lang attribute of a page's
+// <html> element, or an empty string when there isn't one.
+func htmlLanguage(data []byte) string {
+	m := htmlLangRegex.FindSubmatch(data)
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
+// documentLanguage works out the language a page is written in, a language
+// declared by the page itself is preferred over the Content-Language header,
+// and when neither is present the language is guessed from the page's text.
+// An empty string is returned if the language is still unknown.
+func documentLanguage(data []byte, header http.Header, content string) string {
+	if language := htmlLanguage(data); language != "" {
+		return baseLanguage(language)
+	}
+
+	// Content-Language may list several languages, the first is taken to be
+	// the main one.
+	if language := baseLanguage(strings.Split(header.Get("Content-Language"), ",")[0]); language != "" {
+		return language
+	}
+
+	if config().Analysis.DetectLanguage {
+		return DetectLanguage(content)
+	}
+	return ""
 }
 
 // Crawler grabs the contents of a URL and passes the data to Odlaw for
-// processing, it is then written in bulk to the database.
-func Crawler(url string, session *rdb.Session) error {
-	data, err := grabURL(url)
+// processing, it is then written in bulk to the store. The document's language
+// is taken from the page when it's declared, otherwise it's detected from the
+// text, and is used to pick the analyzer its words are indexed with. Crawling
+// a URL again replaces its document and indexes with the page's current
+// content.
+func Crawler(url string, store Store) error {
+	data, header, err := grabURL(url)
 	if err != nil {
 		return err
 	}
@@ -37,11 +77,7 @@ func Crawler(url string, session *rdb.Session) error {
 	d.Title = title
 	d.Author = author
 	d.Content = content
+	d.Language = documentLanguage(data, header, content)
 
-	_ = d.Put(session)
-
-	indexes := Indexer(content, d.ID)
-
-	_ = IndexBatchPut(session, indexes)
-	return nil
+	return d.Upsert(store)
 }
@@ -21,25 +21,117 @@ func TestCrawl_grabURL(t *testing.T) {
 	ts := Handler(status, data)
 	defer ts.Close()
 
-	d, err := grabURL(ts.URL)
+	d, _, err := grabURL(ts.URL)
 	assert.Equal(t, d, data)
 	assert.NoError(t, err)
 }
 
 func TestCrawl_Crawler(t *testing.T) {
-	defer tearDbDown(session)
+	defer tearDbDown(store)
 
 	data := []byte("really cool stuff")
 	ts := Handler(200, data)
 	defer ts.Close()
 
-	err := Crawler(ts.URL, session)
+	err := Crawler(ts.URL, store)
 	assert.NoError(t, err)
 }
 
+func TestCrawl_CrawlerRecrawl(t *testing.T) {
+	defer tearDbDown(store)
+
+	data := "Hank sells propane"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	assert.NoError(t, Crawler(ts.URL, store))
+
+	data = "Hank drinks beer"
+	assert.NoError(t, Crawler(ts.URL, store))
+
+	results, err := Search("propane", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), results.Count)
+
+	results, err = Search("beer", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), results.Count)
+
+	results, err = Search("hank", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), results.Count)
+}
+
+func TestCrawl_CrawlerLanguage(t *testing.T) {
+	defer tearDbDown(store)
+
+	tests := []struct {
+		Header   string
+		Data     string
+		Language string
+	}{
+		{"", `<html lang="de-AT"><body>Hallo</body></html>`, "de"},
+		{"", `<HTML xml:lang='fr'><body>Bonjour</body></HTML>`, "fr"},
+		{"es-ES, en", `<html><body>Hola</body></html>`, "es"},
+		{"it", `<html lang="fr"><body>Bonjour</body></html>`, "fr"},
+		{"", "Die Kinder gingen langsam durch die ruhigen Straßen der Stadt zur Schule und sprachen über die Ferien.", "de"},
+		{"", "hi", ""},
+	}
+
+	for _, test := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.Header != "" {
+				w.Header().Set("Content-Language", test.Header)
+			}
+			w.Write([]byte(test.Data))
+		}))
+
+		err := Crawler(ts.URL, store)
+		assert.NoError(t, err)
+
+		doc, err := store.GetDocument(ts.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, test.Language, doc.Language, test.Data)
+
+		ts.Close()
+	}
+}
+
+func TestCrawl_CrawlerLanguageSearch(t *testing.T) {
+	defer tearDbDown(store)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html lang="de"><body>Die Katzen spielen im Garten</body></html>`))
+	}))
+	defer ts.Close()
+
+	assert.NoError(t, Crawler(ts.URL, store))
+	assert.NoError(t, (&Document{ID: "2", Language: "de", Content: "Der Garten ist groß"}).Upsert(store))
+
+	for _, query := range []string{"Katzen", "Garten", "garten katzen"} {
+		results, err := Search(query, store, 1)
+		assert.NoError(t, err, query)
+		assert.NotZero(t, results.Count, query)
+	}
+
+	results, err := Search("Garten", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), results.Count)
+}
+
+func TestCrawl_documentLanguageNoDetection(t *testing.T) {
+	Conf.Analysis.DetectLanguage = false
+	defer func() { Conf.Analysis.DetectLanguage = true }()
+
+	text := "Die Kinder gingen langsam durch die ruhigen Straßen der Stadt zur Schule und sprachen über die Ferien."
+	assert.Equal(t, "", documentLanguage([]byte(text), http.Header{}, text))
+}
+
 func TestCrawl_CrawlerNoURL(t *testing.T) {
-	defer tearDbDown(session)
+	defer tearDbDown(store)
 
-	err := Crawler("", session)
+	err := Crawler("", store)
 	assert.Error(t, err)
 }
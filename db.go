@@ -1,9 +1,135 @@
 package wally
 
-import rdb "github.com/dancannon/gorethink"
+import (
+	"errors"
+	"fmt"
 
+	rdb "github.com/dancannon/gorethink"
+)
+
+// Storage engines that can be selected in the storage section of the config.
+const (
+	RethinkEngine = "rethinkdb"
+	DiskEngine    = "disk"
+	MemoryEngine  = "memory"
+)
+
+// ErrEmptyPrefix is returned by DeletePrefix when the prefix is empty, as it
+// would match and delete every document.
+var ErrEmptyPrefix = errors.New("empty prefix would delete every document")
+
+// DefaultStoragePath is the directory used by the disk engine when the config
+// doesn't set one.
+var DefaultStoragePath = "wally-data"
+
+// Store is a storage backend for documents and their indexes. Everything in
+// wally that reads or writes data goes through a Store, so a deployment can
+// swap one backend for another without touching the indexer or search.
 type Store interface {
-	Put(session *rdb.Session) error
+	// PutDocument writes a single document, writing a document whose ID
+	// already exists is an error.
+	PutDocument(doc *Document) error
+
+	// GetDocument returns the document with the given ID.
+	GetDocument(id string) (*Document, error)
+
+	// HasDocuments reports which of the documents with the given IDs exist.
+	HasDocuments(ids []string) (map[string]bool, error)
+
+	// DeleteDocument removes the document with the given ID along with every
+	// index it had, deleting a missing document is not an error.
+	DeleteDocument(id string) error
+
+	// DeletePrefix removes every document whose ID starts with prefix, such
+	// as every page of a site, along with their indexes and returns the
+	// number of documents removed. An empty prefix is an error.
+	DeletePrefix(prefix string) (int, error)
+
+	// ReplaceDocument writes a document and its indexes, replacing any
+	// document with the same ID along with every index it had. How the
+	// indexes are swapped depends on the store, MemoryStore and DiskStore
+	// swap them at once so searches see the old indexes or the new ones,
+	// RethinkStore writes the new ones first and then deletes those left
+	// over, so a search in between can still find the old words.
+	ReplaceDocument(doc *Document, indexes []Index) error
+
+	// PutIndexes writes one or more indexes in bulk, writing an index whose ID
+	// already exists is an error.
+	PutIndexes(indexes []Index) error
+
+	// GetIndexes returns every index for any of the given words.
+	GetIndexes(words []string) ([]Index, error)
+
+	// CountIndexes returns the number of indexes for any of the given words.
+	CountIndexes(words []string) (int64, error)
+
+	// Words returns every distinct indexed word starting with prefix, in
+	// order.
+	Words(prefix string) ([]string, error)
+
+	// FuzzyWords returns every distinct indexed word starting with prefix
+	// whose remainder is no more than distance edits from word, in order.
+	FuzzyWords(prefix, word string, distance int) ([]string, error)
+
+	// Completions returns the n indexed words starting with prefix found in
+	// the most documents, most first and then in order. Words of other
+	// fields, with a colon after prefix, aren't included.
+	Completions(prefix string, n int) ([]Completion, error)
+
+	// Stats returns statistics about the indexed documents, along with the
+	// lengths of the given documents, used to score search results.
+	Stats(documentIDs []string) (*CollectionStats, error)
+
+	// Rebuild resets the backend to an empty state.
+	Rebuild() error
+}
+
+// CollectionStats describes the indexed documents. Documents is the number of
+// documents with indexes, TotalLength is the number of terms across all of them
+// and Lengths holds the number of terms in each requested document. Stop words
+// aren't counted.
+type CollectionStats struct {
+	Documents   int64
+	TotalLength int64
+	Lengths     map[string]int64
+}
+
+// AverageLength returns the average number of terms in a document.
+func (c *CollectionStats) AverageLength() float64 {
+	if c.Documents == 0 {
+		return 0
+	}
+	return float64(c.TotalLength) / float64(c.Documents)
+}
+
+// OpenStore opens the Store selected by the config, when no engine is set the
+// database section is used to connect to RethinkDB.
+func OpenStore(c *Config) (Store, error) {
+	switch c.Storage.Engine {
+	case "", RethinkEngine:
+		session, err := rdb.Connect(rdb.ConnectOpts{
+			Address:  c.Database.Host,
+			Database: c.Database.Name,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return NewRethinkStore(session, c), nil
+	case DiskEngine:
+		path := c.Storage.Path
+		if path == "" {
+			path = DefaultStoragePath
+		}
+		s, err := OpenDiskStore(path)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case MemoryEngine:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage engine %q", c.Storage.Engine)
+	}
 }
 
 func NewDocument(source string) *Document {
@@ -0,0 +1,30 @@
+package wally
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDb_OpenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wally")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := OpenStore(&Config{Storage: Storage{Engine: MemoryEngine}})
+	assert.NoError(t, err)
+	assert.IsType(t, s, new(MemoryStore))
+
+	s, err = OpenStore(&Config{Storage: Storage{Engine: DiskEngine, Path: dir}})
+	assert.NoError(t, err)
+	assert.IsType(t, s, new(DiskStore))
+	s.(*DiskStore).Close()
+
+	s, err = OpenStore(&Config{Storage: Storage{Engine: "mongodb"}})
+	assert.Error(t, err)
+	assert.Nil(t, s)
+}
@@ -0,0 +1,203 @@
+package wally
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ProfileSize is the number of trigrams kept in each language profile and
+// taken from text being classified.
+var ProfileSize = 300
+
+const (
+	// minTrigrams is the fewest distinct trigrams text needs before a guess is
+	// made at its language.
+	minTrigrams = 40
+
+	// maxDetectRunes caps how much of a document is read when detecting its
+	// language, the first few pages of text are plenty.
+	maxDetectRunes = 10000
+)
+
+// languageSamples are short passages of ordinary prose used, along with each
+// language's stop words, to build the trigram profiles DetectLanguage compares
+// text against.
+var languageSamples = map[string]string{
+	"en": `All human beings are born free and equal in dignity and rights. They
+are endowed with reason and conscience and should act towards one another in a
+spirit of brotherhood. Everyone is entitled to all the rights and freedoms set
+forth in this declaration, without distinction of any kind, such as race,
+colour, sex, language, religion, political or other opinion, national or social
+origin, property, birth or other status. Everyone has the right to life, liberty
+and security of person. No one shall be held in slavery or servitude. The
+weather was cold that morning and the children walked slowly to the school
+through the quiet streets of the town, talking about what they would do when the
+holidays finally came and where their families might travel together.`,
+	"de": `Alle Menschen sind frei und gleich an Würde und Rechten geboren. Sie
+sind mit Vernunft und Gewissen begabt und sollen einander im Geist der
+Brüderlichkeit begegnen. Jeder hat Anspruch auf alle in dieser Erklärung
+verkündeten Rechte und Freiheiten ohne irgendeinen Unterschied, etwa nach Rasse,
+Hautfarbe, Geschlecht, Sprache, Religion, politischer oder sonstiger
+Überzeugung, nationaler oder sozialer Herkunft, Vermögen, Geburt oder sonstigem
+Stand. Jeder hat das Recht auf Leben, Freiheit und Sicherheit der Person.
+Niemand darf in Sklaverei oder Leibeigenschaft gehalten werden. Das Wetter war
+kalt an diesem Morgen und die Kinder gingen langsam durch die ruhigen Straßen
+der Stadt zur Schule und sprachen darüber, was sie machen würden, wenn endlich
+die Ferien kommen und wohin ihre Familien zusammen reisen könnten.`,
+	"fr": `Tous les êtres humains naissent libres et égaux en dignité et en
+droits. Ils sont doués de raison et de conscience et doivent agir les uns envers
+les autres dans un esprit de fraternité. Chacun peut se prévaloir de tous les
+droits et de toutes les libertés proclamés dans la présente déclaration, sans
+distinction aucune, notamment de race, de couleur, de sexe, de langue, de
+religion, d'opinion politique ou de toute autre opinion, d'origine nationale ou
+sociale, de fortune, de naissance ou de toute autre situation. Tout individu a
+droit à la vie, à la liberté et à la sûreté de sa personne. Nul ne sera tenu en
+esclavage ni en servitude. Il faisait froid ce matin-là et les enfants
+marchaient lentement vers l'école à travers les rues calmes de la ville, en
+parlant de ce qu'ils feraient quand les vacances arriveraient enfin et de
+l'endroit où leurs familles pourraient voyager ensemble.`,
+	"es": `Todos los seres humanos nacen libres e iguales en dignidad y derechos
+y, dotados como están de razón y conciencia, deben comportarse fraternalmente
+los unos con los otros. Toda persona tiene todos los derechos y libertades
+proclamados en esta declaración, sin distinción alguna de raza, color, sexo,
+idioma, religión, opinión política o de cualquier otra índole, origen nacional o
+social, posición económica, nacimiento o cualquier otra condición. Todo
+individuo tiene derecho a la vida, a la libertad y a la seguridad de su persona.
+Nadie estará sometido a esclavitud ni a servidumbre. Hacía frío esa mañana y los
+niños caminaban despacio hacia la escuela por las calles tranquilas del pueblo,
+hablando de lo que harían cuando por fin llegaran las vacaciones y de los
+lugares adonde sus familias podrían viajar juntas.`,
+	"it": `Tutti gli esseri umani nascono liberi ed eguali in dignità e diritti.
+Essi sono dotati di ragione e di coscienza e devono agire gli uni verso gli
+altri in spirito di fratellanza. Ad ogni individuo spettano tutti i diritti e
+tutte le libertà enunciate nella presente dichiarazione, senza distinzione
+alcuna, per ragioni di razza, di colore, di sesso, di lingua, di religione, di
+opinione politica o di altro genere, di origine nazionale o sociale, di
+ricchezza, di nascita o di altra condizione. Ogni individuo ha diritto alla
+vita, alla libertà ed alla sicurezza della propria persona. Nessun individuo
+potrà essere tenuto in stato di schiavitù o di servitù. Faceva freddo quella
+mattina e i bambini camminavano lentamente verso la scuola per le strade
+tranquille del paese, parlando di quello che avrebbero fatto quando finalmente
+sarebbero arrivate le vacanze e di dove le loro famiglie avrebbero potuto
+viaggiare insieme.`,
+}
+
+var (
+	languageProfiles     map[string]map[string]int
+	languageProfilesOnce sync.Once
+)
+
+// DetectLanguage guesses the language of text, returning the ISO 639-1 code of
+// the closest of the languages in Analyzers that has a sample, or an empty
+// string when text is too short to tell.
+//
+// Text is compared using the out-of-place measure described by Cavnar and
+// Trenkle in "N-Gram-Based Text Categorization", each language is ranked by how
+// far its most frequent trigrams are from those of the text.
+func DetectLanguage(text string) string {
+	languageProfilesOnce.Do(buildLanguageProfiles)
+
+	profile := trigramProfile(text)
+	if len(profile) < minTrigrams {
+		return ""
+	}
+
+	best, bestDistance := "", -1
+	for language, ranks := range languageProfiles {
+		if _, ok := Analyzers[language]; !ok {
+			continue
+		}
+		d := profileDistance(profile, ranks)
+		if bestDistance < 0 || d < bestDistance || (d == bestDistance && language < best) {
+			best, bestDistance = language, d
+		}
+	}
+	return best
+}
+
+func buildLanguageProfiles() {
+	languageProfiles = map[string]map[string]int{}
+	for language, sample := range languageSamples {
+		text := sample
+		if a, ok := Analyzers[language]; ok {
+			words := make([]string, 0, len(a.StopWords))
+			for word := range a.StopWords {
+				words = append(words, word)
+			}
+			sort.Strings(words)
+			text += " " + strings.Join(words, " ")
+		}
+
+		ranks := map[string]int{}
+		for i, trigram := range trigramProfile(text) {
+			ranks[trigram] = i
+		}
+		languageProfiles[language] = ranks
+	}
+}
+
+// trigramProfile returns the most frequent trigrams in text, most frequent
+// first. Words are lowercased, stripped of anything that isn't a letter and
+// padded with a space either side so word beginnings and endings count.
+func trigramProfile(text string) []string {
+	if r := []rune(text); len(r) > maxDetectRunes {
+		text = string(r[:maxDetectRunes])
+	}
+
+	counts := map[string]int{}
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		w := []rune(" " + strings.ToLower(word) + " ")
+		for i := 0; i+3 <= len(w); i++ {
+			counts[string(w[i:i+3])]++
+		}
+	}
+
+	trigrams := make([]string, 0, len(counts))
+	for trigram := range counts {
+		trigrams = append(trigrams, trigram)
+	}
+	sort.Sort(byFrequency{trigrams, counts})
+
+	if len(trigrams) > ProfileSize {
+		trigrams = trigrams[:ProfileSize]
+	}
+	return trigrams
+}
+
+// profileDistance sums how far each trigram in profile is from its rank in a
+// language's profile, trigrams the language doesn't have cost the most.
+func profileDistance(profile []string, ranks map[string]int) int {
+	distance := 0
+	for i, trigram := range profile {
+		rank, ok := ranks[trigram]
+		if !ok {
+			distance += ProfileSize
+			continue
+		}
+		if rank > i {
+			distance += rank - i
+		} else {
+			distance += i - rank
+		}
+	}
+	return distance
+}
+
+type byFrequency struct {
+	trigrams []string
+	counts   map[string]int
+}
+
+func (f byFrequency) Len() int      { return len(f.trigrams) }
+func (f byFrequency) Swap(i, j int) { f.trigrams[i], f.trigrams[j] = f.trigrams[j], f.trigrams[i] }
+func (f byFrequency) Less(i, j int) bool {
+	a, b := f.trigrams[i], f.trigrams[j]
+	if f.counts[a] != f.counts[b] {
+		return f.counts[a] > f.counts[b]
+	}
+	return a < b
+}
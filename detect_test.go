@@ -0,0 +1,30 @@
+package wally
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect_DetectLanguage(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/test.txt")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		Text     string
+		Language string
+	}{
+		{string(data), "en"},
+		{"Der Hund lief schnell über die Wiese, weil er den Ball seines Besitzers holen wollte. Danach schlief er zufrieden neben dem Ofen ein.", "de"},
+		{"Le chien a couru très vite dans le jardin parce qu'il voulait attraper la balle de son maître. Ensuite il s'est endormi près de la cheminée.", "fr"},
+		{"El perro corrió muy rápido por el jardín porque quería atrapar la pelota de su dueño. Después se quedó dormido junto a la chimenea.", "es"},
+		{"Il cane è corso molto velocemente nel giardino perché voleva prendere la palla del suo padrone. Poi si è addormentato vicino al camino.", "it"},
+		{"hello", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Language, DetectLanguage(test.Text), test.Text)
+	}
+}
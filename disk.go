@@ -0,0 +1,563 @@
+package wally
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MergeFactor controls when a DiskStore merges its segments, the newest
+// segment is merged into the one before it while that one holds no more than
+// MergeFactor times as many entries. Segments grow geometrically, so there are
+// only a logarithmic number of them and each index is rewritten a logarithmic
+// number of times, however many are written.
+var MergeFactor = 2
+
+const (
+	documentLog   = "documents.log"
+	segmentSuffix = ".seg"
+)
+
+// DiskStore is an embedded Store that keeps everything in a single directory,
+// so wally can run without an external database. Documents are appended to a
+// log and each batch of indexes is written to its own immutable segment file,
+// both are replayed into memory when the store is opened.
+type DiskStore struct {
+	mu       sync.Mutex
+	path     string
+	log      *os.File
+	records  int
+	segments []segmentFile
+	next     int
+	mem      *MemoryStore
+}
+
+// segmentFile is the name of a segment and the number of entries, indexes and
+// replaced documents, it holds.
+type segmentFile struct {
+	name string
+	size int
+}
+
+type documentRecord struct {
+	Op       string    `json:"op"`
+	ID       string    `json:"id"`
+	Document *Document `json:"document,omitempty"`
+}
+
+// segment is a batch of indexes, Replaced lists documents whose indexes from
+// earlier segments are removed before the segment's own are added.
+type segment struct {
+	Replaced []string `json:"replaced,omitempty"`
+	Indexes  []Index  `json:"indexes"`
+}
+
+// OpenDiskStore opens the store in the directory at path, creating it when it
+// doesn't exist.
+func OpenDiskStore(path string) (*DiskStore, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &DiskStore{path: path, mem: NewMemoryStore()}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.openLog(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *DiskStore) openLog() error {
+	f, err := os.OpenFile(filepath.Join(s.path, documentLog), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.log = f
+	return nil
+}
+
+func (s *DiskStore) load() error {
+	if err := s.loadDocuments(); err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(s.path)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+
+		var n int
+		if _, err := fmt.Sscanf(name, "%d"+segmentSuffix, &n); err != nil {
+			continue
+		}
+
+		seg, err := readSegment(filepath.Join(s.path, name))
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		s.mem.replaceIndexes(seg.Replaced, seg.Indexes)
+
+		s.segments = append(s.segments, segmentFile{name, len(seg.Indexes) + len(seg.Replaced)})
+		if n >= s.next {
+			s.next = n + 1
+		}
+	}
+	return nil
+}
+
+// loadDocuments replays the document log, a record cut short by a crash is
+// dropped and cut from the end of the log, otherwise the next record appended
+// would follow it and the log couldn't be read again.
+func (s *DiskStore) loadDocuments() error {
+	path := filepath.Join(s.path, documentLog)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) == 0 {
+				return nil
+			}
+			// Every record ends with a newline, so this one is incomplete
+			f.Close()
+			return os.Truncate(path, offset)
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec documentRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("%s: %v", documentLog, err)
+		}
+		offset += int64(len(line))
+		s.records++
+
+		s.mem.DeleteDocument(rec.ID)
+		if rec.Op == "put" && rec.Document != nil {
+			s.mem.PutDocument(rec.Document)
+		}
+	}
+}
+
+func readSegment(path string) (*segment, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := new(segment)
+	if err := json.Unmarshal(data, seg); err != nil {
+		return nil, err
+	}
+	return seg, nil
+}
+
+// writeSegment writes indexes to a new segment, the file is only renamed into
+// place once it is complete so a crash never leaves a partial segment behind.
+// The segment replaces the indexes of the documents in replaced.
+func (s *DiskStore) writeSegment(indexes []Index, replaced ...string) error {
+	sort.Sort(byWord(indexes))
+
+	data, err := json.Marshal(segment{Replaced: replaced, Indexes: indexes})
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%06d%s", s.next, segmentSuffix)
+	if err := writeFileAtomic(filepath.Join(s.path, name), data); err != nil {
+		return err
+	}
+
+	s.segments = append(s.segments, segmentFile{name, len(indexes) + len(replaced)})
+	s.next++
+	return nil
+}
+
+// mergeSegments merges the newest segment into the one before it while that
+// one is no more than MergeFactor times its size. Only the segments merged are
+// read and written, the older, larger ones are left alone until enough has
+// been written after them.
+func (s *DiskStore) mergeSegments() error {
+	for n := len(s.segments); n > 1; n = len(s.segments) {
+		older, newer := s.segments[n-2], s.segments[n-1]
+		if older.size > MergeFactor*newer.size {
+			return nil
+		}
+
+		a, err := readSegment(filepath.Join(s.path, older.name))
+		if err != nil {
+			return err
+		}
+		b, err := readSegment(filepath.Join(s.path, newer.name))
+		if err != nil {
+			return err
+		}
+		seg := mergeSegment(a, b)
+		if n == 2 {
+			// There are no earlier indexes left to replace
+			seg.Replaced = nil
+		}
+
+		// A crash before the old segments are removed is harmless, the
+		// merged segment replays the same indexes after them
+		s.segments = s.segments[:n-2]
+		if err := s.writeSegment(seg.Indexes, seg.Replaced...); err != nil {
+			s.segments = append(s.segments, older, newer)
+			return err
+		}
+		for _, f := range []segmentFile{older, newer} {
+			if err := os.Remove(filepath.Join(s.path, f.name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mergeSegment merges segment b into the earlier segment a, giving a segment
+// that has the same effect as a followed by b.
+func mergeSegment(a, b *segment) *segment {
+	replaced := map[string]bool{}
+	for _, id := range b.Replaced {
+		replaced[id] = true
+	}
+
+	seg := &segment{}
+	for _, index := range a.Indexes {
+		if !replaced[index.DocumentID] {
+			seg.Indexes = append(seg.Indexes, index)
+		}
+	}
+	seg.Indexes = append(seg.Indexes, b.Indexes...)
+
+	seg.Replaced = append(seg.Replaced, a.Replaced...)
+	for _, id := range a.Replaced {
+		delete(replaced, id)
+	}
+	for _, id := range b.Replaced {
+		if replaced[id] {
+			seg.Replaced = append(seg.Replaced, id)
+			delete(replaced, id)
+		}
+	}
+	return seg
+}
+
+// compactLog rewrites the document log once it holds more than twice as many
+// records as there are documents, so it's rewritten no more often than every
+// document has been written again.
+func (s *DiskStore) compactLog() error {
+	if s.records <= 2*s.mem.documentCount() {
+		return nil
+	}
+	return s.rewriteLog()
+}
+
+// rewriteLog replaces the document log with one holding only the current
+// documents.
+func (s *DiskStore) rewriteLog() error {
+	docs := s.mem.allDocuments()
+
+	var buf []byte
+	for _, doc := range docs {
+		doc := doc
+		data, err := json.Marshal(documentRecord{Op: "put", ID: doc.ID, Document: &doc})
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+
+	if err := s.log.Close(); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.path, documentLog), buf); err != nil {
+		return err
+	}
+	s.records = len(docs)
+	return s.openLog()
+}
+
+// tidy merges segments and compacts the document log after a write.
+func (s *DiskStore) tidy() error {
+	if err := s.mergeSegments(); err != nil {
+		return err
+	}
+	return s.compactLog()
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// appendDocuments appends records to the document log and syncs it, so a
+// document is on disk before any segment with its indexes.
+func (s *DiskStore) appendDocuments(recs ...documentRecord) error {
+	var buf []byte
+	for _, rec := range recs {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	if _, err := s.log.Write(buf); err != nil {
+		return err
+	}
+	s.records += len(recs)
+	return s.log.Sync()
+}
+
+// PutDocument appends the document to the document log.
+func (s *DiskStore) PutDocument(doc *Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.mem.GetDocument(doc.ID); err == nil {
+		return duplicateKeyError(doc.ID)
+	}
+	if err := s.appendDocuments(documentRecord{Op: "put", ID: doc.ID, Document: doc}); err != nil {
+		return err
+	}
+	return s.mem.PutDocument(doc)
+}
+
+// GetDocument returns the document with the given ID.
+func (s *DiskStore) GetDocument(id string) (*Document, error) {
+	return s.mem.GetDocument(id)
+}
+
+// HasDocuments reports which of the documents with the given IDs exist.
+func (s *DiskStore) HasDocuments(ids []string) (map[string]bool, error) {
+	return s.mem.HasDocuments(ids)
+}
+
+// DeleteDocument writes a segment removing the document's indexes and appends
+// a deletion to the document log.
+func (s *DiskStore) DeleteDocument(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.deleteDocuments([]string{id})
+	return err
+}
+
+// DeletePrefix deletes every document whose ID starts with prefix, their
+// indexes are removed by a single segment.
+func (s *DiskStore) DeletePrefix(prefix string) (int, error) {
+	if prefix == "" {
+		return 0, ErrEmptyPrefix
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := s.mem.documentIDs(prefix)
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return s.deleteDocuments(ids)
+}
+
+// deleteDocuments removes the indexes of the documents before the documents
+// themselves, so a crash part way through never leaves indexes pointing at a
+// deleted document.
+func (s *DiskStore) deleteDocuments(ids []string) (int, error) {
+	if err := s.writeSegment(nil, ids...); err != nil {
+		return 0, err
+	}
+	recs := make([]documentRecord, len(ids))
+	for i, id := range ids {
+		recs[i] = documentRecord{Op: "delete", ID: id}
+	}
+	if err := s.appendDocuments(recs...); err != nil {
+		return 0, err
+	}
+	n := s.mem.removeDocuments(ids)
+	return n, s.tidy()
+}
+
+// ReplaceDocument appends the document to the document log and then writes its
+// indexes to a new segment that replaces its old ones, so they're swapped in a
+// single write. A crash in between leaves the document with its old indexes,
+// never indexes without their document.
+func (s *DiskStore) ReplaceDocument(doc *Document, indexes []Index) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.appendDocuments(documentRecord{Op: "put", ID: doc.ID, Document: doc}); err != nil {
+		return err
+	}
+	if err := s.writeSegment(indexes, doc.ID); err != nil {
+		return err
+	}
+	if err := s.mem.ReplaceDocument(doc, indexes); err != nil {
+		return err
+	}
+	return s.tidy()
+}
+
+// PutIndexes writes every index that doesn't already exist to a new segment,
+// the first duplicate found is reported.
+func (s *DiskStore) PutIndexes(indexes []Index) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	seen := map[string]bool{}
+	accepted := []Index{}
+	for _, index := range indexes {
+		if seen[index.ID] || s.mem.hasIndex(index.ID) {
+			if firstErr == nil {
+				firstErr = duplicateKeyError(index.ID)
+			}
+			continue
+		}
+		seen[index.ID] = true
+		accepted = append(accepted, index)
+	}
+
+	if len(accepted) == 0 {
+		return firstErr
+	}
+
+	if err := s.writeSegment(accepted); err != nil {
+		return err
+	}
+	s.mem.PutIndexes(accepted)
+
+	if err := s.mergeSegments(); err != nil {
+		return err
+	}
+	return firstErr
+}
+
+// GetIndexes returns every index for any of the given words.
+func (s *DiskStore) GetIndexes(words []string) ([]Index, error) {
+	return s.mem.GetIndexes(words)
+}
+
+// CountIndexes returns the number of indexes for any of the given words.
+func (s *DiskStore) CountIndexes(words []string) (int64, error) {
+	return s.mem.CountIndexes(words)
+}
+
+// Words returns the indexed words starting with prefix, in order.
+func (s *DiskStore) Words(prefix string) ([]string, error) {
+	return s.mem.Words(prefix)
+}
+
+// FuzzyWords returns the indexed words starting with prefix whose remainder is
+// within distance edits of word, in order.
+func (s *DiskStore) FuzzyWords(prefix, word string, distance int) ([]string, error) {
+	return s.mem.FuzzyWords(prefix, word, distance)
+}
+
+// Completions returns the indexed words starting with prefix found in the most
+// documents.
+func (s *DiskStore) Completions(prefix string, n int) ([]Completion, error) {
+	return s.mem.Completions(prefix, n)
+}
+
+// Stats returns statistics about the indexed documents.
+func (s *DiskStore) Stats(documentIDs []string) (*CollectionStats, error) {
+	return s.mem.Stats(documentIDs)
+}
+
+// Compact merges every segment into one and rewrites the document log without
+// deleted documents. Segments are merged as they're written, so it's only
+// needed to reclaim all the space at once.
+func (s *DiskStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compact()
+}
+
+func (s *DiskStore) compact() error {
+	old := s.segments
+	s.segments = nil
+
+	if indexes := s.mem.allIndexes(); len(indexes) > 0 {
+		if err := s.writeSegment(indexes); err != nil {
+			s.segments = old
+			return err
+		}
+	}
+
+	for _, f := range old {
+		if err := os.Remove(filepath.Join(s.path, f.name)); err != nil {
+			return err
+		}
+	}
+	return s.rewriteLog()
+}
+
+// Rebuild removes every segment and empties the document log.
+func (s *DiskStore) Rebuild() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, f := range s.segments {
+		if err := os.Remove(filepath.Join(s.path, f.name)); err != nil {
+			return err
+		}
+	}
+	s.segments = nil
+	s.next = 0
+	s.records = 0
+
+	if err := s.log.Truncate(0); err != nil {
+		return err
+	}
+	return s.mem.Rebuild()
+}
+
+// Close closes the document log, it's synced as it's written.
+func (s *DiskStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.log.Close()
+}
@@ -0,0 +1,228 @@
+package wally
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tempDiskStore(t *testing.T) (*DiskStore, string) {
+	dir, err := ioutil.TempDir("", "wally")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, dir
+}
+
+func TestDiskStore_Reopen(t *testing.T) {
+	s, dir := tempDiskStore(t)
+	defer os.RemoveAll(dir)
+
+	doc := Document{ID: "http://example.com", Title: "Examples", Content: "example content"}
+	assert.NoError(t, doc.Put(s))
+	assert.NoError(t, IndexBatchPut(s, Indexer(doc.Content, doc.ID)))
+
+	gone := Document{ID: "http://example.org"}
+	assert.NoError(t, gone.Put(s))
+	assert.NoError(t, s.DeleteDocument(gone.ID))
+
+	assert.NoError(t, s.Close())
+
+	s, err := OpenDiskStore(dir)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	d, err := s.GetDocument(doc.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, d.Title, "Examples")
+
+	_, err = s.GetDocument(gone.ID)
+	assert.Equal(t, err, ErrDocumentNotFound)
+
+	count, err := s.CountIndexes([]string{"exampl", "content"})
+	assert.NoError(t, err)
+	assert.Equal(t, count, int64(2))
+
+	indexes, err := s.GetIndexes([]string{"content"})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, indexes[0].Positions)
+
+	stats, err := s.Stats([]string{doc.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Documents)
+	assert.Equal(t, map[string]int64{doc.ID: 2}, stats.Lengths)
+
+	// Writes after reopening carry on from the existing segments.
+	assert.Error(t, doc.Put(s))
+	assert.Error(t, IndexBatchPut(s, Indexer(doc.Content, doc.ID)))
+	assert.NoError(t, IndexBatchPut(s, Indexer("example", "http://example.net")))
+
+	count, _ = s.CountIndexes([]string{"exampl"})
+	assert.Equal(t, count, int64(2))
+}
+
+func TestDiskStore_ReopenTornLog(t *testing.T) {
+	s, dir := tempDiskStore(t)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, (&Document{ID: "1", Content: "Hank sells propane"}).Upsert(s))
+	assert.NoError(t, s.Close())
+
+	// A crash part way through writing a record leaves the start of it behind
+	f, err := os.OpenFile(filepath.Join(dir, documentLog), os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"op":"put","id":"2","docu`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	s, err = OpenDiskStore(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, (&Document{ID: "3", Content: "Hank drinks beer"}).Upsert(s))
+	assert.NoError(t, s.Close())
+
+	s, err = OpenDiskStore(dir)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	for _, id := range []string{"1", "3"} {
+		_, err := s.GetDocument(id)
+		assert.NoError(t, err, id)
+	}
+	_, err = s.GetDocument("2")
+	assert.Equal(t, ErrDocumentNotFound, err)
+}
+
+func TestDiskStore_ReplaceDocument(t *testing.T) {
+	s, dir := tempDiskStore(t)
+	defer os.RemoveAll(dir)
+
+	doc := &Document{ID: "http://example.com", Content: "Hank sells propane"}
+	assert.NoError(t, doc.Upsert(s))
+
+	doc.Content = "Hank drinks beer"
+	assert.NoError(t, doc.Upsert(s))
+	assert.NoError(t, s.Close())
+
+	s, err := OpenDiskStore(dir)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	d, err := s.GetDocument(doc.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hank drinks beer", d.Content)
+
+	count, err := s.CountIndexes([]string{"hank", "sell", "propan", "drink", "beer"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	stats, err := s.Stats([]string{doc.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{doc.ID: 3}, stats.Lengths)
+
+	// Compacting keeps only the current indexes
+	assert.NoError(t, s.Compact())
+	count, _ = s.CountIndexes([]string{"sell", "drink"})
+	assert.Equal(t, int64(1), count)
+}
+
+func TestDiskStore_DeletePrefix(t *testing.T) {
+	s, dir := tempDiskStore(t)
+	defer os.RemoveAll(dir)
+
+	for _, id := range []string{"http://example.com/a", "http://example.com/b", "http://example.org"} {
+		doc := &Document{ID: id, Content: "Hank sells propane"}
+		assert.NoError(t, doc.Upsert(s))
+	}
+
+	n, err := s.DeletePrefix("http://example.com/")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, s.Close())
+
+	s, err = OpenDiskStore(dir)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	_, err = s.GetDocument("http://example.com/a")
+	assert.Equal(t, ErrDocumentNotFound, err)
+
+	count, err := s.CountIndexes([]string{"hank"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// An empty prefix would match every document
+	_, err = s.DeletePrefix("")
+	assert.Equal(t, ErrEmptyPrefix, err)
+	_, err = s.GetDocument("http://example.org")
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.DeleteDocument("http://example.org"))
+	count, _ = s.CountIndexes([]string{"hank"})
+	assert.Equal(t, int64(0), count)
+}
+
+func TestDiskStore_Compact(t *testing.T) {
+	s, dir := tempDiskStore(t)
+	defer os.RemoveAll(dir)
+	defer s.Close()
+
+	for i := 0; i < 64; i++ {
+		doc := &Document{ID: strconv.Itoa(i % 40), Content: "compacted segment " + strconv.Itoa(i)}
+		assert.NoError(t, doc.Upsert(s))
+	}
+	assert.NoError(t, s.DeleteDocument("0"))
+
+	// Segments are merged as they grow rather than all at once, so there are
+	// only a few of them
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	assert.True(t, len(segments) <= 7, "%d segments", len(segments))
+	assert.True(t, s.records <= 2*39, "%d records", s.records)
+
+	s.Close()
+	s, err := OpenDiskStore(dir)
+	assert.NoError(t, err)
+
+	count, _ := s.CountIndexes([]string{"compact"})
+	assert.Equal(t, int64(39), count)
+	count, _ = s.CountIndexes([]string{"63"})
+	assert.Equal(t, int64(1), count)
+	count, _ = s.CountIndexes([]string{"40"})
+	assert.Equal(t, int64(0), count)
+
+	assert.NoError(t, s.Compact())
+	segments, _ = filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	assert.Len(t, segments, 1)
+	count, _ = s.CountIndexes([]string{"compact"})
+	assert.Equal(t, int64(39), count)
+}
+
+func TestDiskStore_Rebuild(t *testing.T) {
+	s, dir := tempDiskStore(t)
+	defer os.RemoveAll(dir)
+
+	doc := Document{ID: "http://example.com"}
+	assert.NoError(t, doc.Put(s))
+	assert.NoError(t, IndexBatchPut(s, Indexer("rebuilt", doc.ID)))
+
+	assert.NoError(t, DatabaseRebuild(s))
+	assert.NoError(t, s.Close())
+
+	s, err := OpenDiskStore(dir)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	_, err = s.GetDocument(doc.ID)
+	assert.Equal(t, err, ErrDocumentNotFound)
+
+	count, _ := s.CountIndexes([]string{"rebuilt"})
+	assert.Equal(t, count, int64(0))
+}
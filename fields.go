@@ -0,0 +1,72 @@
+package wally
+
+import "strings"
+
+// Document fields that are indexed, a query can be limited to one of them by
+// writing the field name and a colon before a word, as in title:hill.
+const (
+	ContentField = "content"
+	TitleField   = "title"
+	AuthorField  = "author"
+)
+
+// Fields lists the indexed fields of a document, a word without a field is
+// searched for in all of them.
+var Fields = []string{ContentField, TitleField, AuthorField}
+
+// fieldTerm returns the word a term is indexed under for a field, terms from a
+// document's content are indexed as they are and terms from other fields are
+// prefixed with the field's name, so "hill" in a title is "title:hill". Words
+// can't contain a colon so the two never clash.
+func fieldTerm(field, term string) string {
+	if field == "" || field == ContentField {
+		return term
+	}
+	return field + ":" + term
+}
+
+// fieldTerms returns the words terms are indexed under for field, or for every
+// field indexed as words when field is empty.
+func fieldTerms(field string, terms []string) []string {
+	words := []string{}
+	for _, f := range termFields(field) {
+		for _, term := range terms {
+			words = append(words, fieldTerm(f, term))
+		}
+	}
+	return words
+}
+
+// termFields returns the fields a term in field is searched for in, field
+// itself or when it's empty every field that's indexed as words rather than
+// n-grams.
+func termFields(field string) []string {
+	if field != "" {
+		return []string{field}
+	}
+
+	fields := []string{}
+	for _, f := range Fields {
+		if !isNGramField(f) {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// isField reports whether name is one of the indexed fields.
+func isField(name string) bool {
+	return containsString(Fields, strings.ToLower(name))
+}
+
+// boost returns how much the score of a match in field is multiplied by, fields
+// without a boost aren't changed.
+func (s Scoring) boost(field string) float64 {
+	if field == "" {
+		field = ContentField
+	}
+	if b, ok := s.Boosts[field]; ok {
+		return b
+	}
+	return 1
+}
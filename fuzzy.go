@@ -0,0 +1,149 @@
+package wally
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultEditDistance is the number of edits a fuzzy word such as rutherfrod~
+// is allowed when the query doesn't give one.
+const DefaultEditDistance = 2
+
+// fuzzyRegex matches a fuzzy word of a query, a word followed by ~ and an
+// optional edit distance.
+var fuzzyRegex = regexp.MustCompile(`^(.+)~(\d*)$`)
+
+// fuzzy is a word of a query followed by ~, it matches indexed terms no more
+// than Distance edits from any of Words, the terms the word is analysed as.
+// Before searching it's expanded into those terms, which become the Terms it's
+// searched for as.
+type fuzzy struct {
+	term
+	Words    []string
+	Distance int
+}
+
+// expander is a matcher that has to look up the terms it matches before the
+// search is run.
+type expander interface {
+	expand(store Store) error
+}
+
+// fuzzyNode returns the matcher for a fuzzy word in field, distance is the
+// number of edits given in the query. The distance is at most
+// Expansion.MaxEdits.
+func fuzzyNode(text, distance, field string) node {
+	words := SplitTextIntoWords(text)
+	if len(words) != 1 {
+		return wordNode(text, field)
+	}
+
+	d := DefaultEditDistance
+	if distance != "" {
+		d, _ = strconv.Atoi(distance)
+	}
+	if max := config().Expansion.MaxEdits; d > max {
+		d = max
+	}
+
+	terms := QueryTerms(words[0])
+	if len(terms) == 0 {
+		return nil
+	}
+
+	return &fuzzy{
+		term:     term{Text: FoldCase(words[0]), Field: field},
+		Words:    terms,
+		Distance: d,
+	}
+}
+
+// expand looks up the indexed terms close enough to the fuzzy word's terms, at
+// most Expansion.MaxTerms of them, closest first, are searched for.
+func (f *fuzzy) expand(store Store) error {
+	distances := map[string]int{}
+	for _, word := range f.Words {
+		for _, field := range termFields(f.Field) {
+			name := fieldTerm(field, "")
+			found, err := store.FuzzyWords(name, word, f.Distance)
+			if err != nil {
+				return err
+			}
+
+			for _, w := range found {
+				// Words from other fields start with the field's name
+				// and a colon
+				t := strings.TrimPrefix(w, name)
+				if strings.Contains(t, ":") {
+					continue
+				}
+
+				d := editDistance(word, t)
+				if current, ok := distances[t]; !ok || d < current {
+					distances[t] = d
+				}
+			}
+		}
+	}
+
+	terms := make([]string, 0, len(distances))
+	for t := range distances {
+		terms = append(terms, t)
+	}
+	sort.Sort(byDistance{terms, distances})
+
+	if max := config().Expansion.MaxTerms; max > 0 && len(terms) > max {
+		terms = terms[:max]
+	}
+	f.Terms = terms
+	return nil
+}
+
+// editDistance returns the number of edits needed to turn a into b, counting
+// inserting, removing or changing a character or swapping two characters next
+// to each other as one edit each.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+
+	rows := make([][]int, len(s)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(t)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			rows[i][j] = minInt(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				rows[i][j] = minInt(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(s)][len(t)]
+}
+
+// byDistance orders terms by their edit distance, then by length and then
+// alphabetically.
+type byDistance struct {
+	terms     []string
+	distances map[string]int
+}
+
+func (b byDistance) Len() int      { return len(b.terms) }
+func (b byDistance) Swap(i, j int) { b.terms[i], b.terms[j] = b.terms[j], b.terms[i] }
+func (b byDistance) Less(i, j int) bool {
+	x, y := b.terms[i], b.terms[j]
+	if b.distances[x] != b.distances[y] {
+		return b.distances[x] < b.distances[y]
+	}
+	return byLength(b.terms).Less(i, j)
+}
@@ -0,0 +1,72 @@
+package wally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzy_editDistance(t *testing.T) {
+	tests := []struct {
+		A, B     string
+		Distance int
+	}{
+		{"rutherford", "rutherford", 0},
+		{"rutherfrod", "rutherford", 1},
+		{"rutherfod", "rutherford", 1},
+		{"ruterfrod", "rutherford", 2},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+		{"", "abc", 3},
+		{"ca", "abc", 3},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Distance, editDistance(test.A, test.B), test.A+" "+test.B)
+		assert.Equal(t, test.Distance, editDistance(test.B, test.A), test.B+" "+test.A)
+	}
+}
+
+func TestFuzzy_fuzzyNode(t *testing.T) {
+	assert.Equal(t, &fuzzy{
+		term:     term{Text: "rutherfrod", Field: "title"},
+		Words:    []string{"rutherfrod"},
+		Distance: 1,
+	}, fuzzyNode("Rutherfrod", "1", "title"))
+
+	f := fuzzyNode("houses", "", "").(*fuzzy)
+	assert.Equal(t, []string{"hous"}, f.Words)
+	assert.Equal(t, DefaultEditDistance, f.Distance)
+
+	f = fuzzyNode("houses", "5", "").(*fuzzy)
+	assert.Equal(t, 2, f.Distance)
+
+	assert.Nil(t, fuzzyNode("the", "1", ""))
+}
+
+func TestFuzzy_expand(t *testing.T) {
+	defer tearDbDown(store)
+
+	doc := &Document{ID: "1", Title: "Rutherford", Content: "Rutherford, Rutherfordton and Ruth further along"}
+	assert.NoError(t, IndexBatchPut(store, IndexDocument(doc)))
+
+	f := fuzzyNode("rutherfrod", "2", "").(*fuzzy)
+	assert.NoError(t, f.expand(store))
+	assert.Equal(t, []string{"rutherford"}, f.Terms)
+
+	f = fuzzyNode("ruth", "2", "").(*fuzzy)
+	assert.NoError(t, f.expand(store))
+	assert.Equal(t, []string{"ruth"}, f.Terms)
+
+	f = fuzzyNode("rutherfrod", "1", "author").(*fuzzy)
+	assert.NoError(t, f.expand(store))
+	assert.Equal(t, []string{}, f.Terms)
+
+	Conf.Expansion.MaxTerms = 1
+	defer func() { Conf.Expansion.MaxTerms = DefaultConfig().Expansion.MaxTerms }()
+
+	// furthe is stemmed to furth, one edit from ruth and two from further
+	f = fuzzyNode("furthe", "2", "").(*fuzzy)
+	assert.NoError(t, f.expand(store))
+	assert.Equal(t, []string{"ruth"}, f.Terms)
+}
@@ -1,12 +1,10 @@
 package wally
 
 import (
-	"errors"
 	"fmt"
+	"runtime"
 	"strings"
 	"sync"
-
-	rdb "github.com/dancannon/gorethink"
 )
 
 var stopWords = map[string]bool{
@@ -187,32 +185,48 @@ var stopWords = map[string]bool{
 }
 
 // Document holds data about a document, ID is usually populated with a UUID.
+// Language selects the Analyzer used to index the document, the configured
+// default language is used when it is empty.
 type Document struct {
-	ID      string `gorethink:"id"`
-	Title   string `gorethink:"title"`
-	Author  string `gorethink:"author"`
-	Content string `gorethink:"content"`
+	ID       string `gorethink:"id"`
+	Title    string `gorethink:"title"`
+	Author   string `gorethink:"author"`
+	Content  string `gorethink:"content"`
+	Language string `gorethink:"language"`
 }
 
 func (d *Document) String() string {
 	return fmt.Sprintf("Document#%s", d.ID)
 }
 
-// Put writes a single document to the database, if an ID isn't set
-// then one is set as a UUID.
-func (d *Document) Put(session *rdb.Session) error {
-	res, _ := rdb.DB(Conf.Database.Name).Table(Conf.Tables.DocumentTable).Insert(d).RunWrite(session)
-	if res.Errors > 0 {
-		return errors.New(res.FirstError)
-	}
-	return nil
+// Put writes a single document to the store.
+func (d *Document) Put(store Store) error {
+	return store.PutDocument(d)
+}
+
+// Upsert writes the document to the store along with the indexes from
+// IndexDocument, replacing the document if it's already stored and swapping its
+// old indexes for the new ones, so indexing a page again reflects what it
+// contains now.
+func (d *Document) Upsert(store Store) error {
+	return store.ReplaceDocument(d, IndexDocument(d))
 }
 
 // Index holds data about an index for a document, ID is populated with a UUID.
+// Word is the normalised term, when Analysis.KeepSurface is set Surface holds
+// the word as it was first seen in the document before stemming. Positions
+// lists where each occurrence of the word falls among the document's terms,
+// stop words aren't counted, so "king of the hill" has "king" and "hill" next
+// to each other. Field is the document field the word is found in, it's empty
+// for the content, and Word is prefixed with the field for any other field, see
+// fieldTerm.
 type Index struct {
 	ID         string `gorethink:"id"`
 	Word       string `gorethink:"word"`
+	Field      string `gorethink:"field,omitempty"`
+	Surface    string `gorethink:"surface,omitempty"`
 	Count      int64  `gorethink:"count"`
+	Positions  []int  `gorethink:"positions,omitempty"`
 	DocumentID string `gorethink:"document_id"`
 }
 
@@ -225,52 +239,50 @@ func (i *Index) String() string {
 	return fmt.Sprintf("Index#%s", i.ID)
 }
 
-// Put writes a single index to the database, if an ID isn't set then one
-// is set as a UUID.
-func (i *Index) Put(session *rdb.Session) error {
+// Put writes a single index to the store, if an ID isn't set then one is
+// generated from the document ID and word.
+func (i *Index) Put(store Store) error {
 	if i.ID == "" {
 		i.GenerateID()
 	}
-	res, _ := rdb.DB(Conf.Database.Name).Table(Conf.Tables.IndexTable).Insert(i).RunWrite(session)
-	if res.Errors > 0 {
-		return errors.New(res.FirstError)
-	}
-	return nil
+	return store.PutIndexes([]Index{*i})
 }
 
-// IndexBatchPut writes one or more indexes in bulk to the database. indexes is
+// IndexBatchPut writes one or more indexes in bulk to the store. indexes is
 // usually created by Indexer() so that each index is given an ID.
-func IndexBatchPut(session *rdb.Session, indexes []Index) error {
-	res, _ := rdb.DB(Conf.Database.Name).Table(Conf.Tables.IndexTable).Insert(indexes).RunWrite(session)
-	if res.Errors > 0 {
-		return errors.New(res.FirstError)
-	}
-	return nil
+func IndexBatchPut(store Store, indexes []Index) error {
+	return store.PutIndexes(indexes)
 }
 
 // SplitTextIntoWords when given a blob of text, as a string or slice of bytes,
-// split each word separted by whitespace, extra whitespace should be removed,
+// splits it into words using Tokenize, whitespace and punctuation are removed,
 // any other type returns an empty string and therefore will not be processed later.
 func SplitTextIntoWords(text interface{}) []string {
 	words := ToString(text)
-	return strings.Fields(words)
+	return Tokenize(words)
 }
 
 // Stopper compares a given word to a list of stopper words (words which are
-// common and therefore should be ignored when indexing).
+// common and therefore should be ignored when indexing) for the default
+// language.
 func Stopper(word string) string {
-	if _, ok := stopWords[word]; ok {
-		return ""
-	}
-	return word
+	return LookupAnalyzer("").Stop(word)
+}
+
+// Normalise case folds a word, removes stop words and stems it using the
+// default language, an empty string is returned for words that shouldn't be
+// indexed.
+func Normalise(word string) string {
+	return LookupAnalyzer("").Normalise(word)
 }
 
 // RemoveDuplicates removes any duplicates results found in an Index slice,
 // when a duplicate is found, the count is incremented when seen and added if
-// it is the first time.
+// it is the first time. The positions of duplicates are merged.
 func RemoveDuplicates(i []Index) []Index {
 	result := []Index{}
 	seen := map[string]int64{}
+	positions := map[string][]int{}
 	for _, val := range i {
 		if _, ok := seen[val.Word]; !ok {
 			// val.Count = val.Count + 1
@@ -279,6 +291,7 @@ func RemoveDuplicates(i []Index) []Index {
 		} else {
 			seen[val.Word] = seen[val.Word] + 1
 		}
+		positions[val.Word] = append(positions[val.Word], val.Positions...)
 	}
 
 	finalResults := []Index{}
@@ -286,46 +299,137 @@ func RemoveDuplicates(i []Index) []Index {
 	for _, res := range result {
 		count := seen[res.Word]
 		res.Count = count
+		res.Positions = positions[res.Word]
+		if len(res.Positions) == 0 {
+			res.Positions = nil
+		}
 		finalResults = append(finalResults, res)
 	}
 	return finalResults
 }
 
+// IndexWorkers is the most goroutines used to analyse the words of a single
+// document, it defaults to the number of CPUs.
+var IndexWorkers = runtime.NumCPU()
+
+// wordsPerWorker is the fewest distinct words given to each worker, below this
+// starting a goroutine costs more than it saves.
+const wordsPerWorker = 256
+
 // Indexer takes text of type string, []byte, or integer (anything else will
-// be treated as an empty string). It then concurrently processes each word in
-// the string before removing any duplicates.
+// be treated as an empty string). It then processes each distinct word in the
+// string, in parallel for larger texts, before counting the terms found.
+// Indexes are returned in the order their words first appear. Words are
+// analysed in the default language.
 func Indexer(text interface{}, documentID string) []Index {
+	return indexText(text, documentID, "", LookupAnalyzer(""))
+}
+
+// IndexDocument indexes the content, title and author of a document using the
+// analyzer for the document's language, or the default language's when queries
+// aren't analysed in it. Each field is indexed separately so a search can be
+// limited to one of them.
+func IndexDocument(doc *Document) []Index {
+	analyzer := documentAnalyzer(doc.Language)
+
+	indexes := indexText(doc.Content, doc.ID, "", analyzer)
+	indexes = append(indexes, indexText(doc.Title, doc.ID, TitleField, analyzer)...)
+	indexes = append(indexes, indexText(doc.Author, doc.ID, AuthorField, analyzer)...)
+	return indexes
+}
+
+func indexText(text interface{}, documentID, field string, analyzer *Analyzer) []Index {
+	if isNGramField(field) || (field == "" && isNGramField(ContentField)) {
+		return indexNGrams(text, documentID, field)
+	}
+
 	// Divide into individual words
 	words := SplitTextIntoWords(text)
 
-	var normalisedWords []Index
-	var wg sync.WaitGroup
+	// Words repeat a lot, so each distinct word is only analysed once
+	distinct := []string{}
+	seen := map[string]int{}
+	for _, word := range words {
+		if _, ok := seen[word]; !ok {
+			seen[word] = len(distinct)
+			distinct = append(distinct, word)
+		}
+	}
+
+	terms := normaliseWords(distinct, analyzer)
+
+	keepSurface := config().Analysis.KeepSurface
 
-	wg.Add(len(words))
+	indexes := []Index{}
+	found := map[string]int{}
+	position := 0
 	for _, word := range words {
-		go func(word string) {
-			defer wg.Done()
+		term := terms[seen[word]]
+		if term == "" {
+			continue
+		}
 
-			// Lowercase words
-			word = strings.ToLower(word)
+		if i, ok := found[term]; ok {
+			indexes[i].Count++
+			indexes[i].Positions = append(indexes[i].Positions, position)
+			position++
+			continue
+		}
 
-			// Remove stopper words
-			word = Stopper(word)
+		index := NewIndex(fieldTerm(field, term), documentID)
+		index.Field = field
+		index.Count = 1
+		index.Positions = []int{position}
+		if keepSurface {
+			index.Surface = strings.ToLower(word)
+		}
+		index.GenerateID()
 
-			if word == "" || len(word) < 2 {
-				return
-			}
+		found[term] = len(indexes)
+		indexes = append(indexes, *index)
+		position++
+	}
+
+	return indexes
+}
 
-			// Apply stemming
+// normaliseWords normalises each word with analyzer, returning the terms in the
+// same order. The words are split between up to IndexWorkers goroutines, each
+// writing only to its own part of the result.
+func normaliseWords(words []string, analyzer *Analyzer) []string {
+	terms := make([]string, len(words))
 
-			// Append to normalised word list
-			index := NewIndex(word, documentID)
-			index.GenerateID()
-			normalisedWords = append(normalisedWords, *index)
-		}(word)
+	workers := IndexWorkers
+	if n := len(words) / wordsPerWorker; n < workers {
+		workers = n
 	}
 
+	if workers <= 1 {
+		for i, word := range words {
+			terms[i] = analyzer.Normalise(word)
+		}
+		return terms
+	}
+
+	size := (len(words) + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(words); start += size {
+		end := start + size
+		if end > len(words) {
+			end = len(words)
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+
+			for i := start; i < end; i++ {
+				terms[i] = analyzer.Normalise(words[i])
+			}
+		}(start, end)
+	}
 	wg.Wait()
 
-	return RemoveDuplicates(normalisedWords)
+	return terms
 }
@@ -1,13 +1,11 @@
 package wally
 
 import (
-	"fmt"
 	"io/ioutil"
-	"os"
 	"strings"
+	"sync"
 	"testing"
 
-	rdb "github.com/dancannon/gorethink"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,41 +22,21 @@ tables:
 )
 
 var (
-	session *rdb.Session
+	store Store
 )
 
 func init() {
 	var err error
 	Conf, err = LoadConfig([]byte(TestConfig))
 	if err != nil {
-		fmt.Errorf(err.Error())
+		panic(err)
 	}
 
-	session, err = rdb.Connect(rdb.ConnectOpts{
-		Address:  os.Getenv("RETHINKDB_URL"),
-		Database: "test",
-	})
-
-	if err != nil {
-		fmt.Errorf(err.Error())
-	}
-
-	// Reset database
-	rdb.DBCreate(Conf.Database.Name).Exec(session)
-	rdb.DB(Conf.Database.Name).TableCreate(Conf.Tables.DocumentTable).Exec(session)
-	rdb.DB(Conf.Database.Name).TableCreate(Conf.Tables.IndexTable).Exec(session)
-	rdb.DB(Conf.Database.Name).Table(Conf.Tables.IndexTable).IndexCreate("word").Exec(session)
+	store = NewMemoryStore()
 }
 
-func tearDbDown(session *rdb.Session) {
-	rdb.DB(Conf.Database.Name).Table(Conf.Tables.DocumentTable).Delete(rdb.DeleteOpts{
-		Durability:    "soft",
-		ReturnChanges: false,
-	}).Exec(session)
-	rdb.DB(Conf.Database.Name).Table(Conf.Tables.IndexTable).Delete(rdb.DeleteOpts{
-		Durability:    "soft",
-		ReturnChanges: false,
-	}).Exec(session)
+func tearDbDown(store Store) {
+	store.Rebuild()
 }
 
 func TestIndexer_Stopper(t *testing.T) {
@@ -92,6 +70,47 @@ func TestIndexer_Stopper(t *testing.T) {
 	}
 }
 
+func TestIndexer_StopperConfigured(t *testing.T) {
+	Conf.Analysis.StopWords.Words = []string{"propane", "a"}
+	assert.NoError(t, Conf.Analysis.StopWords.Load())
+	defer func() {
+		Conf.Analysis.StopWords.Words = nil
+		Conf.Analysis.StopWords.Load()
+	}()
+
+	assert.Equal(t, "", Stopper("propane"))
+	assert.Equal(t, "", Stopper("a"))
+	assert.Equal(t, "", Stopper("the"))
+	assert.Equal(t, "beer", Stopper("beer"))
+
+	// Other languages keep their own stop words
+	assert.Equal(t, "", LookupAnalyzer("de").Stop("und"))
+	assert.Equal(t, "propane", LookupAnalyzer("de").Stop("propane"))
+}
+
+func TestIndexer_StopperReplaced(t *testing.T) {
+	Conf.Analysis.StopWords.Words = []string{"propane", "a"}
+	Conf.Analysis.StopWords.Replace = true
+	assert.NoError(t, Conf.Analysis.StopWords.Load())
+	defer func() {
+		Conf.Analysis.StopWords = DefaultConfig().Analysis.StopWords
+		Conf.Analysis.StopWords.Load()
+	}()
+
+	assert.Equal(t, "", Stopper("propane"))
+	assert.Equal(t, "", Stopper("a"))
+	assert.Equal(t, "the", Stopper("the"))
+}
+
+func TestIndexer_StopperDisabled(t *testing.T) {
+	Conf.Analysis.StopWords.Disabled = true
+	defer func() { Conf.Analysis.StopWords.Disabled = false }()
+
+	assert.Equal(t, "the", Stopper("the"))
+	assert.Equal(t, "und", LookupAnalyzer("de").Stop("und"))
+	assert.Equal(t, "the", Normalise("the"))
+}
+
 func TestIndexer_SplitTextIntoWords(t *testing.T) {
 	tests := []struct {
 		Input  interface{}
@@ -114,7 +133,12 @@ func TestIndexer_SplitTextIntoWords(t *testing.T) {
 
 		{
 			[]byte("fancy a byte?"),
-			[]string{"fancy", "a", "byte?"},
+			[]string{"fancy", "a", "byte"},
+		},
+
+		{
+			"Hank lives in the (fictional) city of Arlen, Texas.",
+			[]string{"Hank", "lives", "in", "the", "fictional", "city", "of", "Arlen", "Texas"},
 		},
 
 		{
@@ -168,6 +192,121 @@ func TestIndexer_Indexer(t *testing.T) {
 	}
 }
 
+func TestIndexer_Normalise(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output string
+	}{
+		{"Examples", "exampl"},
+		{"example", "exampl"},
+		{"The", ""},
+		{"x", ""},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Output, Normalise(test.Input))
+	}
+}
+
+func TestIndexer_IndexerKeepSurface(t *testing.T) {
+	Conf.Analysis.KeepSurface = true
+	defer func() { Conf.Analysis.KeepSurface = false }()
+
+	indexes := Indexer("Examples", "1")
+	assert.Equal(t, len(indexes), 1)
+	assert.Equal(t, indexes[0].Word, "exampl")
+	assert.Equal(t, indexes[0].Surface, "examples")
+	assert.Equal(t, indexes[0].ID, "1::exampl")
+}
+
+func TestIndexer_IndexerCounts(t *testing.T) {
+	file, err := ioutil.ReadFile("test_data/test.txt")
+	assert.NoError(t, err)
+
+	// Count each term the slow way
+	counts := map[string]int64{}
+	for _, word := range SplitTextIntoWords(file) {
+		if term := Normalise(word); term != "" {
+			counts[term]++
+		}
+	}
+
+	workers := IndexWorkers
+	defer func() { IndexWorkers = workers }()
+
+	var previous []Index
+	for _, n := range []int{1, 2, 8} {
+		IndexWorkers = n
+
+		indexes := Indexer(file, "1")
+		assert.Equal(t, len(counts), len(indexes))
+		for _, index := range indexes {
+			assert.Equal(t, counts[index.Word], index.Count, index.Word)
+			assert.Equal(t, "1::"+index.Word, index.ID)
+		}
+
+		if previous != nil {
+			assert.Equal(t, previous, indexes)
+		}
+		previous = indexes
+	}
+
+	assert.Equal(t, "hank", previous[0].Word)
+}
+
+func TestIndexer_IndexerPositions(t *testing.T) {
+	indexes := Indexer("King of the Hill, hill", "1")
+	assert.Equal(t, len(indexes), 2)
+
+	assert.Equal(t, "king", indexes[0].Word)
+	assert.Equal(t, []int{0}, indexes[0].Positions)
+	assert.Equal(t, "hill", indexes[1].Word)
+	assert.Equal(t, []int{1, 2}, indexes[1].Positions)
+	assert.Equal(t, int64(2), indexes[1].Count)
+}
+
+func TestIndexer_IndexerConcurrent(t *testing.T) {
+	file, err := ioutil.ReadFile("test_data/test.txt")
+	assert.NoError(t, err)
+
+	expected := Indexer(file, "1")
+
+	results := make([][]Index, 4)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = Indexer(file, "1")
+		}(i)
+	}
+	wg.Wait()
+
+	for _, indexes := range results {
+		assert.Equal(t, expected, indexes)
+	}
+}
+
+func TestIndexer_IndexerNoStemming(t *testing.T) {
+	Conf.Analysis.Stemming = false
+	defer func() { Conf.Analysis.Stemming = true }()
+
+	indexes := Indexer("Examples", "1")
+	assert.Equal(t, len(indexes), 1)
+	assert.Equal(t, indexes[0].Word, "examples")
+}
+
+func TestIndexer_IndexerFoldDiacritics(t *testing.T) {
+	assert.NotEqual(t, Normalise("café"), Normalise("cafe"))
+	assert.Equal(t, Normalise("ＣＡＦＥ"), Normalise("cafe"))
+
+	Conf.Analysis.FoldDiacritics = true
+	defer func() { Conf.Analysis.FoldDiacritics = false }()
+
+	assert.Equal(t, Normalise("café"), Normalise("cafe"))
+	assert.Equal(t, Normalise("CAFÉS"), Normalise("cafes"))
+}
+
 func TestIndexer_IndexString(t *testing.T) {
 	indexID := "world"
 
@@ -177,19 +316,18 @@ func TestIndexer_IndexString(t *testing.T) {
 }
 
 func TestIndexer_IndexPut(t *testing.T) {
-	defer tearDbDown(session)
+	defer tearDbDown(store)
 
 	index := Index{Word: "hello", Count: 5, DocumentID: "12345-67890-ABCDE"}
 
-	err := index.Put(session)
+	err := index.Put(store)
 	assert.Nil(t, err)
 
-	res, err := rdb.DB(Conf.Database.Name).Table(Conf.Tables.IndexTable).Get(index.ID).Run(session)
+	res, err := store.GetIndexes([]string{"hello"})
 	assert.Nil(t, err)
+	assert.Equal(t, len(res), 1)
 
-	var i Index
-	err = res.One(&i)
-	assert.Nil(t, err)
+	i := res[0]
 
 	assert.Equal(t, i.ID, "12345-67890-ABCDE::hello")
 	assert.Equal(t, i.Word, "hello")
@@ -198,15 +336,15 @@ func TestIndexer_IndexPut(t *testing.T) {
 }
 
 func TestIndexer_IndexPutInvalid(t *testing.T) {
-	defer tearDbDown(session)
+	defer tearDbDown(store)
 
 	i := Index{ID: "1"}
 	i2 := Index{ID: "1"}
 
-	err := i.Put(session)
+	err := i.Put(store)
 	assert.NoError(t, err)
 
-	err = i2.Put(session)
+	err = i2.Put(store)
 	assert.Error(t, err)
 }
 
@@ -221,7 +359,7 @@ func TestIndexer_IndexBatchPut(t *testing.T) {
 			Word: "dupe",
 		},
 	}
-	err := IndexBatchPut(session, indexes)
+	err := IndexBatchPut(store, indexes)
 	assert.Error(t, err)
 }
 
@@ -234,21 +372,17 @@ func TestIndexer_DocumentString(t *testing.T) {
 }
 
 func TestIndexer_DocumentPut(t *testing.T) {
-	defer tearDbDown(session)
+	defer tearDbDown(store)
 
 	doc := Document{
 		ID:      "www.google.com",
 		Content: "Lorem ipsum dolor sit amet.",
 	}
 
-	err := doc.Put(session)
-	assert.Nil(t, err)
-
-	res, err := rdb.DB(Conf.Database.Name).Table(Conf.Tables.DocumentTable).Run(session)
+	err := doc.Put(store)
 	assert.Nil(t, err)
 
-	var d Document
-	err = res.One(&d)
+	d, err := store.GetDocument("www.google.com")
 	assert.Nil(t, err)
 
 	assert.Equal(t, d.ID, "www.google.com")
@@ -256,15 +390,15 @@ func TestIndexer_DocumentPut(t *testing.T) {
 }
 
 func TestIndexer_DocumentPutDupeDocs(t *testing.T) {
-	defer tearDbDown(session)
+	defer tearDbDown(store)
 
 	doc1 := Document{ID: "1"}
 	doc2 := Document{ID: "1"}
 
-	err := doc1.Put(session)
+	err := doc1.Put(store)
 	assert.NoError(t, err)
 
-	err = doc2.Put(session)
+	err = doc2.Put(store)
 	assert.Error(t, err)
 }
 
@@ -282,6 +416,18 @@ func TestIndexer_RemoveDuplicates(t *testing.T) {
 	assert.Equal(t, len(indexes), 3)
 }
 
+func TestIndexer_RemoveDuplicatesPositions(t *testing.T) {
+	indexes := RemoveDuplicates([]Index{
+		Index{Word: "hill", Positions: []int{1}},
+		Index{Word: "king", Positions: []int{0}},
+		Index{Word: "hill", Positions: []int{4}},
+	})
+
+	assert.Equal(t, len(indexes), 2)
+	assert.Equal(t, []int{1, 4}, indexes[0].Positions)
+	assert.Equal(t, int64(2), indexes[0].Count)
+}
+
 func BenchmarkSplitTextIntoWords(b *testing.B) {
 	file, err := ioutil.ReadFile("test_data/test.txt") // 30654 words
 	if err != nil {
@@ -346,6 +492,22 @@ func BenchmarkIndexer(b *testing.B) {
 	}
 }
 
+func BenchmarkIndexer_oneWorker(b *testing.B) {
+	docID := "12345-67890-ABCDE"
+	file, err := ioutil.ReadFile("test_data/test.txt") // 30654 words
+	if err != nil {
+		b.Error("Could not load test data")
+	}
+
+	workers := IndexWorkers
+	IndexWorkers = 1
+	defer func() { IndexWorkers = workers }()
+
+	for n := 0; n < b.N; n++ {
+		Indexer(file, docID)
+	}
+}
+
 func BenchmarkIndexer_two(b *testing.B) {
 	docID := "12345-67890-ABCDE"
 	file, err := ioutil.ReadFile("test_data/test_2.txt") // 30654 words
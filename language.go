@@ -0,0 +1,135 @@
+package wally
+
+import "strings"
+
+// Analyzer holds the stop words and stemmer used to analyse text written in
+// one language.
+type Analyzer struct {
+	Language  string
+	StopWords map[string]bool
+	Stemmer   func(string) string
+}
+
+// Analyzers holds an Analyzer for each supported language keyed by its ISO
+// 639-1 code, other languages can be added before any text is indexed.
+var Analyzers = map[string]*Analyzer{
+	"en": {Language: "en", StopWords: stopWords, Stemmer: Stem},
+	"de": {Language: "de", StopWords: germanStopWords, Stemmer: StemGerman},
+	"fr": {Language: "fr", StopWords: frenchStopWords, Stemmer: StemFrench},
+	"es": {Language: "es", StopWords: spanishStopWords, Stemmer: StemSpanish},
+	"it": {Language: "it", StopWords: italianStopWords, Stemmer: StemItalian},
+}
+
+// LookupAnalyzer returns the Analyzer for a language such as "de" or "fr-CA",
+// the analyzer for the configured default language is returned when the
+// language is empty or unsupported.
+func LookupAnalyzer(language string) *Analyzer {
+	if a, ok := Analyzers[baseLanguage(language)]; ok {
+		return a
+	}
+	if a, ok := Analyzers[baseLanguage(config().Analysis.Language)]; ok {
+		return a
+	}
+	return Analyzers["en"]
+}
+
+// documentAnalyzer returns the Analyzer used for text written in language, the
+// default language's is used for any language queries aren't analysed in,
+// otherwise a document could only be found by words as the query's analyzers
+// see them, not as its own does.
+func documentAnalyzer(language string) *Analyzer {
+	base := baseLanguage(language)
+	for _, l := range queryLanguages() {
+		if baseLanguage(l) == base {
+			return LookupAnalyzer(language)
+		}
+	}
+	return LookupAnalyzer("")
+}
+
+// baseLanguage reduces a language tag such as "en-GB" to its primary subtag.
+func baseLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if i := strings.IndexAny(language, "-_"); i >= 0 {
+		language = language[:i]
+	}
+	return language
+}
+
+// Stop returns an empty string when word is one of the analyzer's stop words,
+// or one of the configured stop words when this is the default language's
+// analyzer.
+func (a *Analyzer) Stop(word string) string {
+	if a.isStopWord(word) {
+		return ""
+	}
+	return word
+}
+
+// isStopWord reports whether word is a stop word, the configured stop words
+// are added to the default language's unless they replace them.
+func (a *Analyzer) isStopWord(word string) bool {
+	s := config().Analysis.StopWords
+	if s.Disabled {
+		return false
+	}
+	if s.set != nil && a == LookupAnalyzer("") {
+		if s.set[word] {
+			return true
+		}
+		if s.Replace {
+			return false
+		}
+	}
+	return a.StopWords[word]
+}
+
+// Normalise case folds a word, removes stop words and stems it when stemming
+// is enabled, accents are then removed if FoldDiacritics is set. An empty
+// string is returned for words that shouldn't be indexed.
+func (a *Analyzer) Normalise(word string) string {
+	// Normalise and case fold words
+	word = FoldCase(word)
+
+	// Remove stopper words
+	word = a.Stop(word)
+
+	if word == "" || len(word) < 2 {
+		return ""
+	}
+
+	// Apply stemming
+	if config().Analysis.Stemming && a.Stemmer != nil {
+		word = a.Stemmer(word)
+	}
+
+	// Accents are removed last, the stemmers rely on them
+	if config().Analysis.FoldDiacritics {
+		word = FoldDiacritics(word)
+	}
+
+	return word
+}
+
+// QueryTerms normalises a query word with the default language and every other
+// language in Analysis.Languages, returning each distinct term. Documents may
+// be indexed in any of these languages so the query has to match them all.
+func QueryTerms(word string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+
+	for _, language := range queryLanguages() {
+		term := LookupAnalyzer(language).Normalise(word)
+		if term != "" && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// queryLanguages returns the default language followed by any others listed in
+// Analysis.Languages.
+func queryLanguages() []string {
+	return append([]string{config().Analysis.Language}, config().Analysis.Languages...)
+}
@@ -0,0 +1,102 @@
+package wally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanguage_LookupAnalyzer(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output string
+	}{
+		{"de", "de"},
+		{"fr-CA", "fr"},
+		{"ES", "es"},
+		{"", "en"},
+		{"xx", "en"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Output, LookupAnalyzer(test.Input).Language)
+	}
+}
+
+func TestLanguage_AnalyzerNormalise(t *testing.T) {
+	de := LookupAnalyzer("de")
+
+	assert.Equal(t, "", de.Normalise("Der"))
+	assert.Equal(t, "", de.Normalise("und"))
+	assert.Equal(t, "haus", de.Normalise("Häuser"))
+
+	fr := LookupAnalyzer("fr")
+
+	assert.Equal(t, "", fr.Normalise("le"))
+	assert.Equal(t, "maison", fr.Normalise("maisons"))
+}
+
+func TestLanguage_IndexDocument(t *testing.T) {
+	Conf.Analysis.Languages = []string{"de"}
+	defer func() { Conf.Analysis.Languages = nil }()
+
+	doc := &Document{ID: "1", Language: "de", Content: "Die Häuser und der Garten"}
+
+	indexes := IndexDocument(doc)
+
+	words := []string{}
+	for _, index := range indexes {
+		words = append(words, index.Word)
+	}
+	assert.Contains(t, words, "haus")
+	assert.Contains(t, words, "gart")
+	assert.NotContains(t, words, "und")
+	assert.NotContains(t, words, "der")
+}
+
+func TestLanguage_IndexDocumentUnlisted(t *testing.T) {
+	doc := &Document{ID: "1", Language: "de", Content: "Die Katzen und der Garten"}
+
+	words := []string{}
+	for _, index := range IndexDocument(doc) {
+		words = append(words, index.Word)
+	}
+	assert.Equal(t, []string{"die", "katzen", "und", "der", "garten"}, words)
+}
+
+func TestLanguage_IndexDocumentFields(t *testing.T) {
+	doc := &Document{ID: "1", Title: "King of the Hill", Author: "Mike Judge", Content: "Hank sells propane"}
+
+	indexes := IndexDocument(doc)
+
+	fields := map[string]string{}
+	for _, index := range indexes {
+		fields[index.Word] = index.Field
+	}
+	assert.Equal(t, map[string]string{
+		"hank":        "",
+		"sell":        "",
+		"propan":      "",
+		"title:king":  "title",
+		"title:hill":  "title",
+		"author:mike": "author",
+		"author:judg": "author",
+	}, fields)
+
+	for _, index := range indexes {
+		if index.Word == "title:hill" {
+			assert.Equal(t, "1::title:hill", index.ID)
+			assert.Equal(t, []int{1}, index.Positions)
+		}
+	}
+}
+
+func TestLanguage_QueryTerms(t *testing.T) {
+	assert.Equal(t, []string{"hous"}, QueryTerms("houses"))
+
+	Conf.Analysis.Languages = []string{"de"}
+	defer func() { Conf.Analysis.Languages = nil }()
+
+	assert.Equal(t, []string{"häuser", "haus"}, QueryTerms("Häuser"))
+	assert.Equal(t, []string{"die"}, QueryTerms("die"))
+}
@@ -0,0 +1,379 @@
+package wally
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps documents and indexes in memory, nothing
+// is persisted so it suits tests and small deployments.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	documents map[string]Document
+	indexes   map[string]Index
+	words     map[string][]string
+	postings  map[string][]string
+	terms     *trie
+	lengths   map[string]int64
+	total     int64
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	s := new(MemoryStore)
+	s.reset()
+	return s
+}
+
+func (s *MemoryStore) reset() {
+	s.documents = map[string]Document{}
+	s.indexes = map[string]Index{}
+	s.words = map[string][]string{}
+	s.postings = map[string][]string{}
+	s.terms = newTrie()
+	s.lengths = map[string]int64{}
+	s.total = 0
+}
+
+func duplicateKeyError(id string) error {
+	return fmt.Errorf("Duplicate primary key `id`: %q", id)
+}
+
+// PutDocument stores a copy of the document.
+func (s *MemoryStore) PutDocument(doc *Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.documents[doc.ID]; ok {
+		return duplicateKeyError(doc.ID)
+	}
+	s.documents[doc.ID] = *doc
+	return nil
+}
+
+// GetDocument returns a copy of the stored document.
+func (s *MemoryStore) GetDocument(id string) (*Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc, ok := s.documents[id]
+	if !ok {
+		return nil, ErrDocumentNotFound
+	}
+	return &doc, nil
+}
+
+// HasDocuments reports which of the documents with the given IDs are stored.
+func (s *MemoryStore) HasDocuments(ids []string) (map[string]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found := map[string]bool{}
+	for _, id := range ids {
+		if _, ok := s.documents[id]; ok {
+			found[id] = true
+		}
+	}
+	return found, nil
+}
+
+// DeleteDocument removes the document and its indexes, deleting a missing
+// document is not an error.
+func (s *MemoryStore) DeleteDocument(id string) error {
+	s.removeDocuments([]string{id})
+	return nil
+}
+
+// DeletePrefix removes every document whose ID starts with prefix and their
+// indexes.
+func (s *MemoryStore) DeletePrefix(prefix string) (int, error) {
+	if prefix == "" {
+		return 0, ErrEmptyPrefix
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, id := range s.idsWithPrefix(prefix) {
+		if s.removeDocument(id) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// removeDocuments removes the documents and their indexes, returning the
+// number of documents that existed.
+func (s *MemoryStore) removeDocuments(ids []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, id := range ids {
+		if s.removeDocument(id) {
+			n++
+		}
+	}
+	return n
+}
+
+// removeDocument removes a document and its indexes, reporting whether the
+// document existed.
+func (s *MemoryStore) removeDocument(id string) bool {
+	_, ok := s.documents[id]
+	delete(s.documents, id)
+	s.removeIndexes(id)
+	return ok
+}
+
+// documentIDs returns the IDs starting with prefix of every document and of
+// every document with indexes, in order.
+func (s *MemoryStore) documentIDs(prefix string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.idsWithPrefix(prefix)
+}
+
+func (s *MemoryStore) idsWithPrefix(prefix string) []string {
+	seen := map[string]bool{}
+	ids := []string{}
+	for id := range s.documents {
+		if strings.HasPrefix(id, prefix) && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for id := range s.postings {
+		if strings.HasPrefix(id, prefix) && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ReplaceDocument stores a copy of the document and its indexes in place of
+// any document with the same ID and the indexes it had, searches see either the
+// old document or the new one.
+func (s *MemoryStore) ReplaceDocument(doc *Document, indexes []Index) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeIndexes(doc.ID)
+	s.documents[doc.ID] = *doc
+	return s.putIndexes(indexes)
+}
+
+// PutIndexes stores every index that doesn't already exist, like RethinkDB the
+// remaining indexes are still written when one of them is a duplicate and the
+// first duplicate is reported.
+func (s *MemoryStore) PutIndexes(indexes []Index) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.putIndexes(indexes)
+}
+
+func (s *MemoryStore) putIndexes(indexes []Index) error {
+	var firstErr error
+	for _, index := range indexes {
+		if _, ok := s.indexes[index.ID]; ok {
+			if firstErr == nil {
+				firstErr = duplicateKeyError(index.ID)
+			}
+			continue
+		}
+		s.indexes[index.ID] = index
+		s.terms.insert(index.Word)
+		s.words[index.Word] = append(s.words[index.Word], index.ID)
+		s.postings[index.DocumentID] = append(s.postings[index.DocumentID], index.ID)
+		s.lengths[index.DocumentID] += index.Count
+		s.total += index.Count
+	}
+	return firstErr
+}
+
+// replaceIndexes removes every index of the documents in replaced and then
+// stores indexes.
+func (s *MemoryStore) replaceIndexes(replaced []string, indexes []Index) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range replaced {
+		s.removeIndexes(id)
+	}
+	return s.putIndexes(indexes)
+}
+
+// removeIndexes removes every index of a document.
+func (s *MemoryStore) removeIndexes(documentID string) {
+	for _, id := range s.postings[documentID] {
+		index := s.indexes[id]
+		delete(s.indexes, id)
+
+		ids := removeString(s.words[index.Word], id)
+		if len(ids) == 0 {
+			delete(s.words, index.Word)
+		} else {
+			s.words[index.Word] = ids
+		}
+		s.terms.add(index.Word, -1)
+		s.total -= index.Count
+	}
+	delete(s.postings, documentID)
+	delete(s.lengths, documentID)
+}
+
+// GetIndexes returns copies of the indexes for the given words.
+func (s *MemoryStore) GetIndexes(words []string) ([]Index, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	indexes := []Index{}
+	for _, word := range words {
+		for _, id := range s.words[word] {
+			indexes = append(indexes, s.indexes[id])
+		}
+	}
+	return indexes, nil
+}
+
+// CountIndexes returns the number of indexes for the given words.
+func (s *MemoryStore) CountIndexes(words []string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int64
+	for _, word := range words {
+		count += int64(len(s.words[word]))
+	}
+	return count, nil
+}
+
+// Words returns the indexed words starting with prefix, in order.
+func (s *MemoryStore) Words(prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.terms.withPrefix(prefix), nil
+}
+
+// FuzzyWords returns the indexed words starting with prefix whose remainder is
+// within distance edits of word, in order.
+func (s *MemoryStore) FuzzyWords(prefix, word string, distance int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.terms.fuzzy(prefix, word, distance), nil
+}
+
+// Completions returns the indexed words starting with prefix found in the most
+// documents.
+func (s *MemoryStore) Completions(prefix string, n int) ([]Completion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.terms.top(prefix, n, false), nil
+}
+
+// Stats returns statistics kept up to date as indexes are stored.
+func (s *MemoryStore) Stats(documentIDs []string) (*CollectionStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := &CollectionStats{
+		Documents:   int64(len(s.lengths)),
+		TotalLength: s.total,
+		Lengths:     map[string]int64{},
+	}
+	for _, id := range documentIDs {
+		if length, ok := s.lengths[id]; ok {
+			stats.Lengths[id] = length
+		}
+	}
+	return stats, nil
+}
+
+// Rebuild discards every document and index.
+func (s *MemoryStore) Rebuild() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reset()
+	return nil
+}
+
+func (s *MemoryStore) hasIndex(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.indexes[id]
+	return ok
+}
+
+// allIndexes returns every index ordered by word.
+func (s *MemoryStore) allIndexes() []Index {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	indexes := make([]Index, 0, len(s.indexes))
+	for _, index := range s.indexes {
+		indexes = append(indexes, index)
+	}
+	sort.Sort(byWord(indexes))
+	return indexes
+}
+
+// documentCount returns the number of documents.
+func (s *MemoryStore) documentCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.documents)
+}
+
+// allDocuments returns every document ordered by ID.
+func (s *MemoryStore) allDocuments() []Document {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.documents))
+	for id := range s.documents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	docs := make([]Document, len(ids))
+	for i, id := range ids {
+		docs[i] = s.documents[id]
+	}
+	return docs
+}
+
+// byWord orders indexes by word and then by ID.
+type byWord []Index
+
+func (b byWord) Len() int      { return len(b) }
+func (b byWord) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byWord) Less(i, j int) bool {
+	if b[i].Word != b[j].Word {
+		return b[i].Word < b[j].Word
+	}
+	return b[i].ID < b[j].ID
+}
+
+// removeString returns values without value, reusing values.
+func removeString(values []string, value string) []string {
+	kept := values[:0]
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,208 @@
+package wally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore_Documents(t *testing.T) {
+	s := NewMemoryStore()
+
+	doc := Document{ID: "http://example.com", Title: "Example"}
+	assert.NoError(t, s.PutDocument(&doc))
+	assert.Error(t, s.PutDocument(&doc))
+
+	d, err := s.GetDocument(doc.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, d.Title, "Example")
+
+	// Changing the returned copy doesn't change the store.
+	d.Title = "Changed"
+	d, _ = s.GetDocument(doc.ID)
+	assert.Equal(t, d.Title, "Example")
+
+	assert.NoError(t, s.DeleteDocument(doc.ID))
+	_, err = s.GetDocument(doc.ID)
+	assert.Equal(t, err, ErrDocumentNotFound)
+}
+
+func TestMemoryStore_Indexes(t *testing.T) {
+	s := NewMemoryStore()
+
+	err := s.PutIndexes([]Index{
+		{ID: "1::hello", Word: "hello", DocumentID: "1", Count: 2},
+		{ID: "2::hello", Word: "hello", DocumentID: "2", Count: 1},
+		{ID: "1::hello", Word: "hello", DocumentID: "1", Count: 2},
+		{ID: "2::world", Word: "world", DocumentID: "2", Count: 1},
+	})
+	assert.Error(t, err)
+
+	indexes, err := s.GetIndexes([]string{"hello"})
+	assert.NoError(t, err)
+	assert.Equal(t, len(indexes), 2)
+
+	count, err := s.CountIndexes([]string{"hello", "world", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, count, int64(3))
+
+	assert.NoError(t, s.Rebuild())
+	count, _ = s.CountIndexes([]string{"hello", "world"})
+	assert.Equal(t, count, int64(0))
+}
+
+func TestMemoryStore_ReplaceDocument(t *testing.T) {
+	s := NewMemoryStore()
+
+	doc := &Document{ID: "1", Content: "Hank sells propane"}
+	assert.NoError(t, s.ReplaceDocument(doc, Indexer(doc.Content, doc.ID)))
+	assert.NoError(t, IndexBatchPut(s, Indexer("propane", "2")))
+
+	doc = &Document{ID: "1", Content: "Hank drinks beer beer"}
+	assert.NoError(t, s.ReplaceDocument(doc, Indexer(doc.Content, doc.ID)))
+
+	d, err := s.GetDocument("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hank drinks beer beer", d.Content)
+
+	count, err := s.CountIndexes([]string{"hank", "sell", "propan", "drink", "beer"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	words, err := s.Words("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"beer", "drink", "hank", "propan"}, words)
+
+	completions, err := s.Completions("", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Completion{{"beer", 1}, {"drink", 1}, {"hank", 1}, {"propan", 1}}, completions)
+
+	stats, err := s.Stats([]string{"1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Documents)
+	assert.Equal(t, int64(5), stats.TotalLength)
+	assert.Equal(t, map[string]int64{"1": 4}, stats.Lengths)
+}
+
+func TestMemoryStore_DeleteDocument(t *testing.T) {
+	s := NewMemoryStore()
+
+	for _, id := range []string{"http://example.com/a", "http://example.com/b", "http://example.org"} {
+		doc := &Document{ID: id, Content: "Hank sells propane"}
+		assert.NoError(t, doc.Upsert(s))
+	}
+
+	assert.NoError(t, s.DeleteDocument("http://example.org"))
+	assert.NoError(t, s.DeleteDocument("http://example.org"))
+	_, err := s.GetDocument("http://example.org")
+	assert.Equal(t, ErrDocumentNotFound, err)
+
+	count, err := s.CountIndexes([]string{"hank"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	n, err := s.DeletePrefix("http://example.com/")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	count, _ = s.CountIndexes([]string{"hank", "sell", "propan"})
+	assert.Equal(t, int64(0), count)
+
+	words, err := s.Words("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, words)
+
+	stats, err := s.Stats(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Documents)
+	assert.Equal(t, int64(0), stats.TotalLength)
+
+	n, err = s.DeletePrefix("http://example.com/")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// An empty prefix would match every document
+	_, err = s.DeletePrefix("")
+	assert.Equal(t, ErrEmptyPrefix, err)
+}
+
+func TestMemoryStore_Stats(t *testing.T) {
+	s := NewMemoryStore()
+
+	assert.NoError(t, s.PutIndexes([]Index{
+		{ID: "1::hello", Word: "hello", DocumentID: "1", Count: 2},
+		{ID: "1::world", Word: "world", DocumentID: "1", Count: 1},
+		{ID: "2::hello", Word: "hello", DocumentID: "2", Count: 3},
+	}))
+	assert.Error(t, s.PutIndexes([]Index{
+		{ID: "1::hello", Word: "hello", DocumentID: "1", Count: 2},
+	}))
+
+	stats, err := s.Stats([]string{"1", "3"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Documents)
+	assert.Equal(t, int64(6), stats.TotalLength)
+	assert.Equal(t, map[string]int64{"1": 3}, stats.Lengths)
+	assert.Equal(t, 3.0, stats.AverageLength())
+
+	assert.NoError(t, s.Rebuild())
+	stats, _ = s.Stats(nil)
+	assert.Equal(t, int64(0), stats.Documents)
+	assert.Equal(t, 0.0, stats.AverageLength())
+}
+
+func TestMemoryStore_Words(t *testing.T) {
+	s := NewMemoryStore()
+
+	assert.NoError(t, s.PutIndexes([]Index{
+		{ID: "1::propan", Word: "propan", DocumentID: "1"},
+		{ID: "1::prop", Word: "prop", DocumentID: "1"},
+		{ID: "2::propan", Word: "propan", DocumentID: "2"},
+		{ID: "2::title:propan", Word: "title:propan", DocumentID: "2"},
+		{ID: "2::hank", Word: "hank", DocumentID: "2"},
+	}))
+
+	words, err := s.Words("prop")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prop", "propan"}, words)
+
+	words, err = s.Words("title:")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"title:propan"}, words)
+
+	words, err = s.Words("beer")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, words)
+
+	words, err = s.FuzzyWords("", "porpan", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"propan"}, words)
+
+	words, err = s.FuzzyWords("title:", "propane", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"title:propan"}, words)
+}
+
+func TestMemoryStore_Completions(t *testing.T) {
+	s := NewMemoryStore()
+
+	assert.NoError(t, s.PutIndexes([]Index{
+		{ID: "1::propan", Word: "propan", DocumentID: "1"},
+		{ID: "1::prop", Word: "prop", DocumentID: "1"},
+		{ID: "2::propan", Word: "propan", DocumentID: "2"},
+		{ID: "2::title:propan", Word: "title:propan", DocumentID: "2"},
+		{ID: "2::hank", Word: "hank", DocumentID: "2"},
+	}))
+
+	completions, err := s.Completions("pro", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Completion{{"propan", 2}, {"prop", 1}}, completions)
+
+	completions, err = s.Completions("", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []Completion{{"propan", 2}, {"hank", 1}}, completions)
+
+	completions, err = s.Completions("title:", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Completion{{"title:propan", 1}}, completions)
+}
@@ -0,0 +1,183 @@
+package wally
+
+import "strings"
+
+// DefaultNGramSize is the number of characters in each n-gram when
+// Analysis.NGrams doesn't give a size.
+const DefaultNGramSize = 3
+
+// isNGramField reports whether field is indexed as n-grams, an empty field
+// means every field and isn't.
+func isNGramField(field string) bool {
+	return field != "" && containsString(config().Analysis.NGrams.Fields, strings.ToLower(field))
+}
+
+// ngramFields returns the fields indexed as n-grams.
+func ngramFields() []string {
+	fields := []string{}
+	for _, field := range Fields {
+		if isNGramField(field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// ngramSize returns the configured n-gram size.
+func ngramSize() int {
+	if n := config().Analysis.NGrams.Size; n > 0 {
+		return n
+	}
+	return DefaultNGramSize
+}
+
+// ngramRuns splits text into runs of characters to cut into n-grams, case
+// folded and with accents removed when FoldDiacritics is set. Words are split
+// as Tokenize splits them, except that characters written without spaces
+// between them, like Chinese and Japanese, stay together in one run.
+func ngramRuns(text string) []string {
+	text = foldGrams(text)
+
+	runs := []string{}
+	end := -1
+	for _, span := range tokenSpans(text) {
+		if len(runs) > 0 && span.Start == end {
+			runs[len(runs)-1] += span.Word
+		} else {
+			runs = append(runs, span.Word)
+		}
+		end = span.End
+	}
+	return runs
+}
+
+// foldGrams case folds text, and removes accents when FoldDiacritics is set,
+// as it is before being cut into n-grams.
+func foldGrams(text string) string {
+	text = FoldCase(text)
+	if config().Analysis.FoldDiacritics {
+		text = FoldDiacritics(text)
+	}
+	return text
+}
+
+// indexGrams cuts a run into the grams it's indexed as at each of its
+// characters, the n-gram starting there followed by every shorter gram, so a
+// part of the run shorter than n is found by looking up a single term.
+func indexGrams(run string, n int) [][]string {
+	runes := []rune(run)
+	grams := make([][]string, len(runes))
+	for i := range runes {
+		end := i + n
+		if end > len(runes) {
+			end = len(runes)
+		}
+		for ; end > i; end-- {
+			grams[i] = append(grams[i], string(runes[i:end]))
+		}
+	}
+	return grams
+}
+
+// queryGrams cuts a run of a query into the n-grams that must be found one
+// after another for it to match, a run shorter than n gives none.
+func queryGrams(run string, n int) []string {
+	runes := []rune(run)
+	grams := []string{}
+	for i := 0; i+n <= len(runes); i++ {
+		grams = append(grams, string(runes[i:i+n]))
+	}
+	return grams
+}
+
+// indexNGrams indexes text in field as n-grams, the n-grams of each run follow
+// one another so a phrase of them matches part of the run. The shorter grams
+// starting at a character share its position.
+func indexNGrams(text interface{}, documentID, field string) []Index {
+	n := ngramSize()
+
+	indexes := []Index{}
+	found := map[string]int{}
+	position := 0
+	for _, run := range ngramRuns(ToString(text)) {
+		for _, grams := range indexGrams(run, n) {
+			for _, gram := range grams {
+				if i, ok := found[gram]; ok {
+					indexes[i].Count++
+					indexes[i].Positions = append(indexes[i].Positions, position)
+					continue
+				}
+
+				index := NewIndex(fieldTerm(field, gram), documentID)
+				index.Field = field
+				index.Count = 1
+				index.Positions = []int{position}
+				index.GenerateID()
+
+				found[gram] = len(indexes)
+				indexes = append(indexes, *index)
+			}
+			position++
+		}
+	}
+	return indexes
+}
+
+// ngramNode returns the matcher for words of a query in a field indexed as
+// n-grams, they match wherever they're found, even in the middle of a word. A
+// run of more than n characters is a phrase of its n-grams and a shorter one
+// is a single gram. Each run has to be found, in any order, so wildcards and
+// fuzzy words are searched for as the words they contain.
+func ngramNode(text, field string) node {
+	n := ngramSize()
+
+	nodes := andNode{}
+	for _, run := range ngramRuns(text) {
+		grams := queryGrams(run, n)
+		switch len(grams) {
+		case 0, 1:
+			nodes = append(nodes, &term{Text: run, Field: field, Terms: []string{run}})
+		default:
+			p := &phrase{Text: run, Field: field}
+			for _, gram := range grams {
+				p.Terms = append(p.Terms, []string{gram})
+			}
+			nodes = append(nodes, p)
+		}
+	}
+
+	switch len(nodes) {
+	case 0:
+		return nil
+	case 1:
+		return nodes[0]
+	}
+	return nodes
+}
+
+// withNGrams adds the matchers for text in each field indexed as n-grams to
+// n, the matcher for text in the other fields, when it isn't limited to a
+// field.
+func withNGrams(n node, text, field string) node {
+	if field != "" {
+		return n
+	}
+
+	nodes := orNode{}
+	if n != nil {
+		nodes = append(nodes, n)
+	}
+	for _, f := range ngramFields() {
+		if g := ngramNode(text, f); g != nil {
+			nodes = append(nodes, g)
+		}
+	}
+
+	switch len(nodes) {
+	case 0:
+		return nil
+	case 1:
+		return nodes[0]
+	}
+	return nodes
+}
@@ -0,0 +1,85 @@
+package wally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNGram_ngramRuns(t *testing.T) {
+	tests := []struct {
+		Text string
+		Runs []string
+	}{
+		{"Hank sells propane", []string{"hank", "sells", "propane"}},
+		{"東京都に住む", []string{"東京都に住む"}},
+		{"東京 タワー", []string{"東京", "タワー"}},
+		{"well-known, U.S.A", []string{"well", "known", "u.s.a"}},
+		{"", []string{}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Runs, ngramRuns(test.Text), test.Text)
+	}
+}
+
+func TestNGram_grams(t *testing.T) {
+	assert.Equal(t, [][]string{{"han", "ha", "h"}, {"ank", "an", "a"}, {"nk", "n"}, {"k"}}, indexGrams("hank", 3))
+	assert.Equal(t, [][]string{{"東京", "東"}, {"京都", "京"}, {"都"}}, indexGrams("東京都", 2))
+	assert.Equal(t, [][]string{{"h"}}, indexGrams("h", 3))
+
+	assert.Equal(t, []string{"han", "ank"}, queryGrams("hank", 3))
+	assert.Equal(t, []string{"東京", "京都"}, queryGrams("東京都", 2))
+	assert.Equal(t, []string{}, queryGrams("ha", 3))
+}
+
+func TestNGram_indexNGrams(t *testing.T) {
+	Conf.Analysis.NGrams.Fields = []string{"title"}
+	defer func() { Conf.Analysis.NGrams = DefaultConfig().Analysis.NGrams }()
+
+	doc := &Document{ID: "1", Title: "Hank hank", Content: "Hank"}
+	assert.Equal(t, []Index{
+		{ID: "1::hank", Word: "hank", Count: 1, Positions: []int{0}, DocumentID: "1"},
+		{ID: "1::title:han", Word: "title:han", Field: "title", Count: 2, Positions: []int{0, 4}, DocumentID: "1"},
+		{ID: "1::title:ha", Word: "title:ha", Field: "title", Count: 2, Positions: []int{0, 4}, DocumentID: "1"},
+		{ID: "1::title:h", Word: "title:h", Field: "title", Count: 2, Positions: []int{0, 4}, DocumentID: "1"},
+		{ID: "1::title:ank", Word: "title:ank", Field: "title", Count: 2, Positions: []int{1, 5}, DocumentID: "1"},
+		{ID: "1::title:an", Word: "title:an", Field: "title", Count: 2, Positions: []int{1, 5}, DocumentID: "1"},
+		{ID: "1::title:a", Word: "title:a", Field: "title", Count: 2, Positions: []int{1, 5}, DocumentID: "1"},
+		{ID: "1::title:nk", Word: "title:nk", Field: "title", Count: 2, Positions: []int{2, 6}, DocumentID: "1"},
+		{ID: "1::title:n", Word: "title:n", Field: "title", Count: 2, Positions: []int{2, 6}, DocumentID: "1"},
+		{ID: "1::title:k", Word: "title:k", Field: "title", Count: 2, Positions: []int{3, 7}, DocumentID: "1"},
+	}, IndexDocument(doc))
+}
+
+func TestNGram_parseQuery(t *testing.T) {
+	Conf.Analysis.NGrams = NGrams{Fields: []string{"title"}, Size: 3}
+	defer func() { Conf.Analysis.NGrams = DefaultConfig().Analysis.NGrams }()
+
+	opan := &phrase{Text: "opan", Field: "title", Terms: [][]string{{"opa"}, {"pan"}}}
+
+	tests := []struct {
+		Query string
+		Node  node
+	}{
+		{"title:opan", opan},
+		{"title:pan", &term{Text: "pan", Field: "title", Terms: []string{"pan"}}},
+		{"title:pa", &term{Text: "pa", Field: "title", Terms: []string{"pa"}}},
+		{
+			`title:"opan pa"`,
+			andNode{opan, &term{Text: "pa", Field: "title", Terms: []string{"pa"}}},
+		},
+		{"title:opan*", opan},
+		{"opan", orNode{&term{Text: "opan", Terms: []string{"opan"}}, opan}},
+		{"the", &term{Text: "the", Field: "title", Terms: []string{"the"}}},
+	}
+
+	for _, test := range tests {
+		n, err := parseQuery(test.Query)
+		assert.NoError(t, err, test.Query)
+		assert.Equal(t, test.Node, n, test.Query)
+	}
+
+	assert.Equal(t, []string{"content", "author"}, termFields(""))
+	assert.Equal(t, []string{"title"}, termFields("title"))
+}
@@ -0,0 +1,64 @@
+package wally
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// FoldCase puts a word into a canonical form for matching, full-width and
+// other compatibility characters are replaced by their usual forms (NFKC) and
+// the word is case folded, which goes further than lowercasing so that "Straße"
+// and "STRASSE" are both "strasse".
+func FoldCase(word string) string {
+	if isASCII(word) {
+		return strings.ToLower(word)
+	}
+
+	// Casers keep state so one can't be shared between the goroutines
+	// indexing a document.
+	word = cases.Fold().String(norm.NFKC.String(word))
+	return norm.NFKC.String(word)
+}
+
+// FoldDiacritics removes accents and other marks from a word so "café" and
+// "cafe" match, letters such as "ø" and "æ" which don't decompose are replaced
+// by the letters they're usually written as.
+func FoldDiacritics(word string) string {
+	if isASCII(word) {
+		return word
+	}
+
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, word)
+	if err != nil {
+		return word
+	}
+
+	return diacriticReplacer.Replace(folded)
+}
+
+var diacriticReplacer = strings.NewReplacer(
+	"æ", "ae",
+	"œ", "oe",
+	"ø", "o",
+	"ł", "l",
+	"đ", "d",
+	"ð", "d",
+	"þ", "th",
+	"ı", "i",
+)
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package wally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize_FoldCase(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output string
+	}{
+		{"Hello", "hello"},
+		{"ＨＥＬＬＯ", "hello"},
+		{"Straße", "strasse"},
+		{"ﬁle", "file"},
+		{"ΣΊΣΥΦΟΣ", "σίσυφοσ"},
+		{"Café", "café"},
+		{"Café", "café"},
+		{"①", "1"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Output, FoldCase(test.Input), test.Input)
+	}
+}
+
+func TestNormalize_FoldDiacritics(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output string
+	}{
+		{"cafe", "cafe"},
+		{"café", "cafe"},
+		{"naïve", "naive"},
+		{"über", "uber"},
+		{"mañana", "manana"},
+		{"søren", "soren"},
+		{"łódź", "lodz"},
+		{"encyclopædia", "encyclopaedia"},
+		{"привет", "привет"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Output, FoldDiacritics(test.Input), test.Input)
+	}
+}
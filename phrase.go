@@ -0,0 +1,197 @@
+package wally
+
+import "sort"
+
+// matcher is a leaf of a parsed query, a term, phrase or proximity match. keys
+// returns the terms whose indexes match needs, match picks out the indexes of
+// the documents it matches, an index for each document.
+type matcher interface {
+	keys() []string
+	match(indexes []Index) []Index
+}
+
+// term is a single word of a query, analysed into each term it could be
+// indexed as. Field is the field it's searched for in, every field when it's
+// empty, as it is for phrases and proximity matches.
+type term struct {
+	Text  string
+	Field string
+	Terms []string
+}
+
+// phrase is a quoted part of a query, each of its words is analysed into the
+// terms it could be indexed as.
+type phrase struct {
+	Text  string
+	Field string
+	Terms [][]string
+}
+
+// proximity is a pair of words joined by NEAR/n, they match when they're
+// within Distance terms of each other in either order and in the same field.
+type proximity struct {
+	Text     string
+	Field    string
+	Terms    [][]string
+	Distance int
+}
+
+// keys returns the words the term is indexed under in its fields.
+func (t term) keys() []string {
+	return fieldTerms(t.Field, t.Terms)
+}
+
+// match returns the indexes for any of the term's terms.
+func (t term) match(indexes []Index) []Index {
+	keys := t.keys()
+
+	matches := []Index{}
+	for _, index := range indexes {
+		if containsString(keys, index.Word) {
+			matches = append(matches, index)
+		}
+	}
+	return matches
+}
+
+// keys returns every term the phrase could match.
+func (p phrase) keys() []string {
+	return fieldTerms(p.Field, flattenTerms(p.Terms))
+}
+
+// match finds the documents in which the phrase's terms appear next to each
+// other and in order, returning an index for each one and field. Its Word is
+// the phrase, Count is the number of times the phrase appears and Positions
+// holds where each occurrence starts.
+func (p phrase) match(indexes []Index) []Index {
+	matches := []Index{}
+	for found, slots := range termPositions(indexes, p.Field, p.Terms) {
+		starts := []int{}
+		for start := range slots[0] {
+			if phraseAt(slots, start) {
+				starts = append(starts, start)
+			}
+		}
+		if len(starts) > 0 {
+			matches = append(matches, positionalMatch(p.Text, found, starts))
+		}
+	}
+	return matches
+}
+
+// phraseAt reports whether every word of a phrase is found in turn from start.
+func phraseAt(slots []map[int]bool, start int) bool {
+	for i, positions := range slots {
+		if !positions[start+i] {
+			return false
+		}
+	}
+	return true
+}
+
+// keys returns every term either word could match.
+func (p proximity) keys() []string {
+	return fieldTerms(p.Field, flattenTerms(p.Terms))
+}
+
+// match finds the documents in which the two words are no more than Distance
+// terms apart, returning an index for each one and field. Its Word is the query
+// text, Count is the number of times the first word has the second nearby and
+// Positions holds where each of those occurrences of the first word are.
+func (p proximity) match(indexes []Index) []Index {
+	matches := []Index{}
+	for found, slots := range termPositions(indexes, p.Field, p.Terms) {
+		starts := []int{}
+		for start := range slots[0] {
+			if p.nearAt(slots[1], start) {
+				starts = append(starts, start)
+			}
+		}
+		if len(starts) > 0 {
+			matches = append(matches, positionalMatch(p.Text, found, starts))
+		}
+	}
+	return matches
+}
+
+// nearAt reports whether any of positions is within Distance of start, a word
+// isn't near itself.
+func (p proximity) nearAt(positions map[int]bool, start int) bool {
+	for d := 1; d <= p.Distance; d++ {
+		if positions[start-d] || positions[start+d] {
+			return true
+		}
+	}
+	return false
+}
+
+// documentField is a field of a document, positions are only comparable
+// within a field.
+type documentField struct {
+	DocumentID string
+	Field      string
+}
+
+// termPositions collects the positions of each slot's terms in every field of
+// every document, limited to field when it's set. A slot is one word of the
+// query and holds each term it could be indexed as.
+func termPositions(indexes []Index, field string, slots [][]string) map[documentField][]map[int]bool {
+	documents := map[documentField][]map[int]bool{}
+	for _, index := range indexes {
+		for i, terms := range slots {
+			if !containsString(fieldTerms(field, terms), index.Word) {
+				continue
+			}
+
+			key := documentField{index.DocumentID, index.Field}
+			positions, ok := documents[key]
+			if !ok {
+				positions = make([]map[int]bool, len(slots))
+				documents[key] = positions
+			}
+			if positions[i] == nil {
+				positions[i] = map[int]bool{}
+			}
+			for _, position := range index.Positions {
+				positions[i][position] = true
+			}
+		}
+	}
+	return documents
+}
+
+// positionalMatch returns the index for a field of a document matched by a
+// phrase or proximity query.
+func positionalMatch(text string, found documentField, starts []int) Index {
+	sort.Ints(starts)
+
+	index := Index{
+		Word:       fieldTerm(found.Field, text),
+		Field:      found.Field,
+		Count:      int64(len(starts)),
+		Positions:  starts,
+		DocumentID: found.DocumentID,
+	}
+	if config().Analysis.KeepSurface {
+		index.Surface = text
+	}
+	index.GenerateID()
+	return index
+}
+
+func flattenTerms(slots [][]string) []string {
+	keys := []string{}
+	for _, terms := range slots {
+		keys = append(keys, terms...)
+	}
+	return keys
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
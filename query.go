@@ -0,0 +1,753 @@
+package wally
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QueryError is returned by Search when a query can't be parsed, Offset is the
+// byte offset in the query at which the problem was found.
+type QueryError struct {
+	Query   string
+	Offset  int
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("query %q: %s at offset %d", e.Query, e.Message, e.Offset)
+}
+
+// A query is parsed into a tree of nodes, the leaves are matchers and the
+// branches combine the documents they match.
+type node interface{}
+
+type (
+	// andNode matches documents matched by every one of its nodes.
+	andNode []node
+
+	// orNode matches documents matched by any one of its nodes.
+	orNode []node
+
+	// notNode matches documents its node doesn't.
+	notNode struct{ node }
+
+	// requiredNode is a node marked with +, it's treated as its node except
+	// in a clauseNode.
+	requiredNode struct{ node }
+
+	// clauseNode is a list of nodes side by side with no operator between
+	// them. When any are required (+) only documents matching all of those
+	// are matched, otherwise documents matching any of the nodes are. Nodes
+	// that are excluded (- or NOT) mustn't match.
+	clauseNode []node
+)
+
+type tokenKind int
+
+const (
+	wordToken tokenKind = iota
+	phraseToken
+	fieldToken
+	andToken
+	orToken
+	notToken
+	nearToken
+	requiredToken
+	excludedToken
+	openToken
+	closeToken
+	endToken
+)
+
+type token struct {
+	Kind   tokenKind
+	Text   string
+	Offset int
+}
+
+var nearRegex = regexp.MustCompile(`^NEAR/(\d+)$`)
+
+// lexQuery splits a query into tokens. Parentheses and quoted phrases stand
+// alone, + and - are operators at the start of a word and AND, OR, NOT and
+// NEAR/n are operators when written in capitals. A field name followed by a
+// colon, as in title:hill, is a token of its own, everything else is a word.
+func lexQuery(query string) ([]token, error) {
+	tokens := []token{}
+
+	i := 0
+	for i < len(query) {
+		c := query[i]
+		switch {
+		case isQuerySpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{openToken, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{closeToken, ")", i})
+			i++
+		case c == '"':
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				return nil, &QueryError{query, i, "missing closing quote"}
+			}
+			tokens = append(tokens, token{phraseToken, query[i+1 : i+1+end], i})
+			i += end + 2
+		case c == '+' || c == '-':
+			// A lone + or - is ignored
+			if i+1 < len(query) && !isQuerySpace(query[i+1]) {
+				kind := requiredToken
+				if c == '-' {
+					kind = excludedToken
+				}
+				tokens = append(tokens, token{kind, string(c), i})
+			}
+			i++
+		default:
+			start := i
+			for i < len(query) && !isQuerySpace(query[i]) && !strings.ContainsRune(`()"`, rune(query[i])) {
+				i++
+			}
+
+			text := query[start:i]
+			if colon := strings.IndexByte(text, ':'); colon > 0 && isField(text[:colon]) {
+				tokens = append(tokens, token{fieldToken, text[:colon+1], start})
+				i = start + colon + 1
+				continue
+			}
+
+			kind := wordToken
+			switch {
+			case text == "AND":
+				kind = andToken
+			case text == "OR":
+				kind = orToken
+			case text == "NOT":
+				kind = notToken
+			case nearRegex.MatchString(text):
+				kind = nearToken
+			}
+			tokens = append(tokens, token{kind, text, start})
+		}
+	}
+
+	return append(tokens, token{endToken, "end of query", len(query)}), nil
+}
+
+func isQuerySpace(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsSpace(rune(c))
+}
+
+// queryParser is a recursive descent parser for the query language:
+//
+//	clauses  = { or }
+//	or       = and { "OR" and }
+//	and      = unary { "AND" unary }
+//	unary    = "NOT" unary | "+" unary | "-" unary | primary
+//	primary  = field primary | "(" clauses ")" | phrase | word [ "NEAR/n" word ]
+//
+// Words and phrases made up only of stop words are dropped from the tree. A
+// field limits the words and phrases in the primary that follows it to that
+// field, otherwise they're searched for in every field, fields indexed as
+// n-grams are searched using ngramNode. A run of words with synonyms is taken
+// as a single word, so lp gas matches what it would without synonyms or any of
+// its synonyms.
+type queryParser struct {
+	query  string
+	tokens []token
+	pos    int
+	field  string
+}
+
+// parseQuery parses a query into a tree of nodes, the tree is nil when there's
+// nothing to search for.
+func parseQuery(query string) (node, error) {
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{query: query, tokens: tokens}
+	n, err := p.clauses()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.Kind != endToken {
+		return nil, p.errorf(t, "unexpected %s", t.Text)
+	}
+
+	// Matching every document but a few isn't something that can be looked
+	// up.
+	if negated(n) {
+		return nil, &QueryError{query, 0, "a query can't only exclude terms"}
+	}
+	return n, nil
+}
+
+func (p *queryParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() token {
+	t := p.tokens[p.pos]
+	if t.Kind != endToken {
+		p.pos++
+	}
+	return t
+}
+
+func (p *queryParser) errorf(t token, format string, args ...interface{}) error {
+	return &QueryError{p.query, t.Offset, fmt.Sprintf(format, args...)}
+}
+
+func (p *queryParser) clauses() (node, error) {
+	nodes := clauseNode{}
+	for {
+		if t := p.peek(); t.Kind == endToken || t.Kind == closeToken {
+			break
+		}
+
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			nodes = append(nodes, n)
+		}
+	}
+
+	switch len(nodes) {
+	case 0:
+		return nil, nil
+	case 1:
+		if r, ok := nodes[0].(requiredNode); ok {
+			return r.node, nil
+		}
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) or() (node, error) {
+	return p.binary(orToken, p.and, func(nodes []node) node { return orNode(nodes) })
+}
+
+func (p *queryParser) and() (node, error) {
+	return p.binary(andToken, p.unary, func(nodes []node) node { return andNode(nodes) })
+}
+
+// binary parses operands separated by the operator kind, combining them with
+// join when there's more than one.
+func (p *queryParser) binary(kind tokenKind, operand func() (node, error), join func([]node) node) (node, error) {
+	if !p.startsOperand() {
+		t := p.peek()
+		return nil, p.errorf(t, "expected a term before %s", t.Text)
+	}
+
+	n, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []node{}
+	if n != nil {
+		nodes = append(nodes, n)
+	}
+
+	for p.peek().Kind == kind {
+		op := p.next()
+		if !p.startsOperand() {
+			return nil, p.errorf(op, "expected a term after %s", op.Text)
+		}
+
+		n, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			nodes = append(nodes, n)
+		}
+	}
+
+	switch len(nodes) {
+	case 0:
+		return nil, nil
+	case 1:
+		return nodes[0], nil
+	}
+	for i, n := range nodes {
+		// + only means something side by side with other clauses
+		if r, ok := n.(requiredNode); ok {
+			nodes[i] = r.node
+		}
+	}
+	return join(nodes), nil
+}
+
+// startsOperand reports whether the next token can begin an operand.
+func (p *queryParser) startsOperand() bool {
+	switch p.peek().Kind {
+	case wordToken, phraseToken, fieldToken, notToken, requiredToken, excludedToken, openToken:
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) unary() (node, error) {
+	t := p.peek()
+	switch t.Kind {
+	case notToken, requiredToken, excludedToken:
+		p.next()
+		if !p.startsOperand() {
+			return nil, p.errorf(t, "expected a term after %s", t.Text)
+		}
+
+		n, err := p.unary()
+		if err != nil || n == nil {
+			return nil, err
+		}
+		if t.Kind == requiredToken {
+			return requiredNode{n}, nil
+		}
+		return notNode{n}, nil
+	}
+	return p.primary()
+}
+
+func (p *queryParser) primary() (node, error) {
+	t := p.next()
+	switch t.Kind {
+	case fieldToken:
+		switch p.peek().Kind {
+		case wordToken, phraseToken, fieldToken, openToken:
+		default:
+			return nil, p.errorf(t, "expected a term after %s", t.Text)
+		}
+
+		field := p.field
+		p.field = strings.ToLower(strings.TrimSuffix(t.Text, ":"))
+		defer func() { p.field = field }()
+		return p.primary()
+	case openToken:
+		if p.peek().Kind == closeToken {
+			return nil, p.errorf(t, "empty parentheses")
+		}
+		n, err := p.clauses()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().Kind != closeToken {
+			return nil, p.errorf(t, "missing closing parenthesis")
+		}
+		return n, nil
+	case phraseToken:
+		return withNGrams(phraseNode(t.Text, p.field), t.Text, p.field), nil
+	case wordToken:
+		// Looking up every term ending in something isn't possible
+		if isWildcard(t.Text) && literalPrefix(t.Text) == "" {
+			return nil, p.errorf(t, "a wildcard can't start a word")
+		}
+
+		if near := p.peek(); near.Kind == nearToken {
+			p.next()
+			right := p.next()
+			if right.Kind != wordToken {
+				return nil, p.errorf(near, "expected a word after %s", near.Text)
+			}
+			n, err := p.proximityNode(t, near, right)
+			if err != nil {
+				return nil, err
+			}
+			return withNGrams(n, t.Text+" "+right.Text, p.field), nil
+		}
+		if texts, synonyms := p.synonymRun(t); synonyms != nil {
+			n := synonymNode(texts, synonyms, p.field)
+			return withNGrams(n, strings.Join(texts, " "), p.field), nil
+		}
+		return withNGrams(wordNode(t.Text, p.field), t.Text, p.field), nil
+	}
+	return nil, p.errorf(t, "unexpected %s", t.Text)
+}
+
+// wordNode returns the matcher for a word of a query in field, or every field
+// indexed as words when it's empty. A word that's split into several, like
+// "well-known", is treated as a phrase, one containing * or ? is a wildcard
+// pattern and one ending in ~ is a fuzzy word.
+func wordNode(text, field string) node {
+	if isNGramField(field) {
+		return ngramNode(text, field)
+	}
+	if isWildcard(text) {
+		return wildcardNode(text, field)
+	}
+	if m := fuzzyRegex.FindStringSubmatch(text); m != nil {
+		return fuzzyNode(m[1], m[2], field)
+	}
+
+	words := SplitTextIntoWords(text)
+	if len(words) > 1 {
+		return phraseNode(text, field)
+	}
+
+	for _, word := range words {
+		if terms := QueryTerms(word); len(terms) > 0 {
+			return &term{Text: strings.ToLower(word), Field: field, Terms: terms}
+		}
+	}
+	return nil
+}
+
+// phraseNode returns the matcher for a phrase in field, a phrase left with a
+// single term once stop words are removed is treated as that word.
+func phraseNode(text, field string) node {
+	if isNGramField(field) {
+		return ngramNode(text, field)
+	}
+
+	words := SplitTextIntoWords(text)
+
+	p := &phrase{Text: strings.ToLower(strings.Join(words, " ")), Field: field}
+	for _, word := range words {
+		if terms := QueryTerms(word); len(terms) > 0 {
+			p.Terms = append(p.Terms, terms)
+		}
+	}
+
+	switch len(p.Terms) {
+	case 0:
+		return nil
+	case 1:
+		return &term{Text: p.Text, Field: field, Terms: p.Terms[0]}
+	}
+	return p
+}
+
+// proximityNode returns the matcher for two words joined by NEAR/n, when either
+// is a stop word the other is matched on its own. Words that aren't matched as
+// a single term, like wildcards or "well-known", can't be joined by NEAR/n.
+func (p *queryParser) proximityNode(left, near, right token) (node, error) {
+	distance, err := strconv.Atoi(nearRegex.FindStringSubmatch(near.Text)[1])
+	if err != nil {
+		return nil, p.errorf(near, "%s is too far apart", near.Text)
+	}
+
+	l, r := wordNode(left.Text, p.field), wordNode(right.Text, p.field)
+	switch {
+	case l == nil:
+		return r, nil
+	case r == nil:
+		return l, nil
+	}
+
+	lt, ok := l.(*term)
+	if !ok {
+		return nil, p.errorf(left, "%s can't be used with %s", left.Text, near.Text)
+	}
+	rt, ok := r.(*term)
+	if !ok {
+		return nil, p.errorf(right, "%s can't be used with %s", right.Text, near.Text)
+	}
+
+	return &proximity{
+		Text:     fmt.Sprintf("%s near/%d %s", lt.Text, distance, rt.Text),
+		Field:    p.field,
+		Terms:    [][]string{lt.Terms, rt.Terms},
+		Distance: distance,
+	}, nil
+}
+
+// negated reports whether a node matches every document except some, which
+// depends only on the shape of the query. This mirrors how sets are combined
+// by andSets and orSets.
+func negated(n node) bool {
+	switch n := n.(type) {
+	case andNode:
+		for _, child := range n {
+			if !negated(child) {
+				return false
+			}
+		}
+		return true
+	case orNode:
+		for _, child := range n {
+			if negated(child) {
+				return true
+			}
+		}
+		return false
+	case notNode:
+		return !negated(n.node)
+	case requiredNode:
+		return negated(n.node)
+	case clauseNode:
+		var required, optional, excluded []bool
+		for _, child := range n {
+			switch child.(type) {
+			case requiredNode:
+				required = append(required, negated(child))
+			case notNode:
+				excluded = append(excluded, negated(child))
+			default:
+				optional = append(optional, negated(child))
+			}
+		}
+
+		if len(required) == 0 {
+			required = []bool{false}
+			for _, neg := range optional {
+				if neg {
+					required[0] = true
+				}
+			}
+			if len(optional) == 0 {
+				required[0] = true
+			}
+		}
+		for _, neg := range append(required, excluded...) {
+			if !neg {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// documentSet is the set of documents matched by part of a query. When Negated
+// is set it holds the documents that aren't matched, so NOT can be evaluated
+// without knowing every document.
+type documentSet struct {
+	IDs     map[string]bool
+	Negated bool
+}
+
+func (s documentSet) minus(o documentSet) map[string]bool {
+	ids := map[string]bool{}
+	for id := range s.IDs {
+		if !o.IDs[id] {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+func (s documentSet) intersect(o documentSet) map[string]bool {
+	ids := map[string]bool{}
+	for id := range s.IDs {
+		if o.IDs[id] {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+func (s documentSet) union(o documentSet) map[string]bool {
+	ids := map[string]bool{}
+	for id := range s.IDs {
+		ids[id] = true
+	}
+	for id := range o.IDs {
+		ids[id] = true
+	}
+	return ids
+}
+
+func andSets(a, b documentSet) documentSet {
+	switch {
+	case !a.Negated && !b.Negated:
+		return documentSet{a.intersect(b), false}
+	case !a.Negated:
+		return documentSet{a.minus(b), false}
+	case !b.Negated:
+		return documentSet{b.minus(a), false}
+	}
+	return documentSet{a.union(b), true}
+}
+
+func orSets(a, b documentSet) documentSet {
+	switch {
+	case !a.Negated && !b.Negated:
+		return documentSet{a.union(b), false}
+	case !a.Negated:
+		return documentSet{b.minus(a), true}
+	case !b.Negated:
+		return documentSet{a.minus(b), true}
+	}
+	return documentSet{a.intersect(b), true}
+}
+
+// queryEvaluator works out which documents match a parsed query from the
+// indexes of every term in it.
+type queryEvaluator struct {
+	indexes []Index
+	matches map[matcher][]Index
+}
+
+func (e *queryEvaluator) eval(n node) documentSet {
+	switch n := n.(type) {
+	case andNode:
+		set := e.eval(n[0])
+		for _, child := range n[1:] {
+			set = andSets(set, e.eval(child))
+		}
+		return set
+	case orNode:
+		set := e.eval(n[0])
+		for _, child := range n[1:] {
+			set = orSets(set, e.eval(child))
+		}
+		return set
+	case notNode:
+		set := e.eval(n.node)
+		set.Negated = !set.Negated
+		return set
+	case requiredNode:
+		return e.eval(n.node)
+	case clauseNode:
+		return e.evalClauses(n)
+	case matcher:
+		ids := map[string]bool{}
+		for _, index := range e.match(n) {
+			ids[index.DocumentID] = true
+		}
+		return documentSet{IDs: ids}
+	}
+	return documentSet{IDs: map[string]bool{}}
+}
+
+func (e *queryEvaluator) evalClauses(nodes clauseNode) documentSet {
+	var required, optional, excluded []node
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case requiredNode:
+			required = append(required, n.node)
+		case notNode:
+			excluded = append(excluded, n)
+		default:
+			optional = append(optional, n)
+		}
+	}
+
+	var set *documentSet
+	combine := func(s documentSet, join func(a, b documentSet) documentSet) {
+		if set == nil {
+			set = &s
+			return
+		}
+		*set = join(*set, s)
+	}
+
+	if len(required) > 0 {
+		for _, n := range required {
+			combine(e.eval(n), andSets)
+		}
+	} else {
+		for _, n := range optional {
+			combine(e.eval(n), orSets)
+		}
+	}
+	for _, n := range excluded {
+		combine(e.eval(n), andSets)
+	}
+	return *set
+}
+
+// match returns the indexes matched by m, they're worked out once per matcher.
+func (e *queryEvaluator) match(m matcher) []Index {
+	if indexes, ok := e.matches[m]; ok {
+		return indexes
+	}
+	indexes := m.match(e.indexes)
+	e.matches[m] = indexes
+	return indexes
+}
+
+// queryMatchers adds the matchers in a query to all, and the ones that aren't
+// excluded by NOT or - to results, their indexes are what's returned.
+func queryMatchers(n node, positive bool, all, results *[]matcher) {
+	switch n := n.(type) {
+	case andNode:
+		for _, child := range n {
+			queryMatchers(child, positive, all, results)
+		}
+	case orNode:
+		for _, child := range n {
+			queryMatchers(child, positive, all, results)
+		}
+	case clauseNode:
+		for _, child := range n {
+			queryMatchers(child, positive, all, results)
+		}
+	case notNode:
+		queryMatchers(n.node, !positive, all, results)
+	case requiredNode:
+		queryMatchers(n.node, positive, all, results)
+	case matcher:
+		*all = append(*all, n)
+		if positive {
+			*results = append(*results, n)
+		}
+	}
+}
+
+// searchIndexes finds the documents matching a parsed query, returning the
+// indexes of each term, phrase and proximity match that found them along with
+// the number of documents each of their words is found in.
+func searchIndexes(root node, store Store) ([]Index, map[string]int64, error) {
+	indexes := []Index{}
+	frequencies := map[string]int64{}
+	if root == nil {
+		return indexes, frequencies, nil
+	}
+
+	var all, results []matcher
+	queryMatchers(root, true, &all, &results)
+
+	for _, m := range all {
+		if e, ok := m.(expander); ok {
+			if err := e.expand(store); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, m := range all {
+		for _, key := range m.keys() {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	found, err := store.GetIndexes(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e := &queryEvaluator{indexes: found, matches: map[matcher][]Index{}}
+	set := e.eval(root)
+
+	ids := map[string]bool{}
+	counted := map[string]bool{}
+	for _, m := range results {
+		for _, index := range e.match(m) {
+			if !counted[index.ID] {
+				counted[index.ID] = true
+				frequencies[index.Word]++
+			}
+
+			if set.IDs[index.DocumentID] && !ids[index.ID] {
+				ids[index.ID] = true
+				indexes = append(indexes, index)
+			}
+		}
+	}
+	return indexes, frequencies, nil
+}
@@ -0,0 +1,212 @@
+package wally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery_lexQuery(t *testing.T) {
+	tokens, err := lexQuery(`+hank -(beer OR "king hill") AND NOT dale NEAR/3 bill well-known - +`)
+	assert.NoError(t, err)
+
+	kinds := []tokenKind{}
+	texts := []string{}
+	for _, token := range tokens {
+		kinds = append(kinds, token.Kind)
+		texts = append(texts, token.Text)
+	}
+
+	assert.Equal(t, []tokenKind{
+		requiredToken, wordToken, excludedToken, openToken, wordToken, orToken,
+		phraseToken, closeToken, andToken, notToken, wordToken, nearToken,
+		wordToken, wordToken, endToken,
+	}, kinds)
+	assert.Equal(t, []string{
+		"+", "hank", "-", "(", "beer", "OR", "king hill", ")", "AND", "NOT",
+		"dale", "NEAR/3", "bill", "well-known", "end of query",
+	}, texts)
+}
+
+func TestQuery_lexQueryFields(t *testing.T) {
+	tokens, err := lexQuery(`title:hill Author:"judge" title:(a b) http://example.com`)
+	assert.NoError(t, err)
+
+	kinds := []tokenKind{}
+	texts := []string{}
+	for _, token := range tokens {
+		kinds = append(kinds, token.Kind)
+		texts = append(texts, token.Text)
+	}
+
+	assert.Equal(t, []tokenKind{
+		fieldToken, wordToken, fieldToken, phraseToken, fieldToken, openToken,
+		wordToken, wordToken, closeToken, wordToken, endToken,
+	}, kinds)
+	assert.Equal(t, []string{
+		"title:", "hill", "Author:", "judge", "title:", "(", "a", "b", ")",
+		"http://example.com", "end of query",
+	}, texts)
+}
+
+func TestQuery_parseQuery(t *testing.T) {
+	hank := &term{Text: "hank", Terms: []string{"hank"}}
+	beer := &term{Text: "beer", Terms: []string{"beer"}}
+	propane := &term{Text: "propane", Terms: []string{"propan"}}
+
+	tests := []struct {
+		Query string
+		Node  node
+	}{
+		{"", nil},
+		{"the", nil},
+		{"hank", hank},
+		{"+hank", hank},
+		{"hank beer", clauseNode{hank, beer}},
+		{"hank the beer", clauseNode{hank, beer}},
+		{"hank OR beer AND propane", orNode{hank, andNode{beer, propane}}},
+		{"(hank OR beer) AND propane", andNode{orNode{hank, beer}, propane}},
+		{"+hank -beer propane", clauseNode{requiredNode{hank}, notNode{beer}, propane}},
+		{"hank AND NOT beer", andNode{hank, notNode{beer}}},
+		{"+hank AND beer", andNode{hank, beer}},
+		{
+			`"King of the Hill"`,
+			&phrase{Text: "king of the hill", Terms: [][]string{{"king"}, {"hill"}}},
+		},
+		{`"the hill"`, &term{Text: "the hill", Terms: []string{"hill"}}},
+		{
+			"well-known",
+			&phrase{Text: "well known", Terms: [][]string{{"well"}, {"known"}}},
+		},
+		{
+			"hank NEAR/5 propane",
+			&proximity{Text: "hank near/5 propane", Terms: [][]string{{"hank"}, {"propan"}}, Distance: 5},
+		},
+		{"the NEAR/5 hank", hank},
+		{"hank near/5 propane", clauseNode{hank, &term{Text: "near 5", Terms: []string{"near"}}, propane}},
+		{"title:hank", &term{Text: "hank", Field: "title", Terms: []string{"hank"}}},
+		{
+			"title:hank author:beer",
+			clauseNode{
+				&term{Text: "hank", Field: "title", Terms: []string{"hank"}},
+				&term{Text: "beer", Field: "author", Terms: []string{"beer"}},
+			},
+		},
+		{
+			`TITLE:"King of the Hill"`,
+			&phrase{Text: "king of the hill", Field: "title", Terms: [][]string{{"king"}, {"hill"}}},
+		},
+		{
+			"title:(hank OR beer) propane",
+			clauseNode{
+				orNode{
+					&term{Text: "hank", Field: "title", Terms: []string{"hank"}},
+					&term{Text: "beer", Field: "title", Terms: []string{"beer"}},
+				},
+				propane,
+			},
+		},
+		{
+			"author:hank NEAR/5 propane",
+			&proximity{Text: "hank near/5 propane", Field: "author", Terms: [][]string{{"hank"}, {"propan"}}, Distance: 5},
+		},
+		{"title:the", nil},
+		{
+			"title:Propan*",
+			&wildcard{term: term{Text: "propan*", Field: "title"}, Patterns: []string{"propan*"}},
+		},
+		{
+			"rutherfrod~1 hank~",
+			clauseNode{
+				&fuzzy{term: term{Text: "rutherfrod"}, Words: []string{"rutherfrod"}, Distance: 1},
+				&fuzzy{term: term{Text: "hank"}, Words: []string{"hank"}, Distance: 2},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		n, err := parseQuery(test.Query)
+		assert.NoError(t, err, test.Query)
+		assert.Equal(t, test.Node, n, test.Query)
+	}
+}
+
+func TestQuery_parseQuerySynonyms(t *testing.T) {
+	Conf.Synonyms.Rules = []string{"propane, lpg", "lp gas => propane, liquefied petroleum gas"}
+	assert.NoError(t, Conf.Synonyms.Load())
+	defer func() {
+		Conf.Synonyms = DefaultConfig().Synonyms
+	}()
+
+	hank := &term{Text: "hank", Terms: []string{"hank"}}
+	propane := &term{Text: "propane", Terms: []string{"propan", "lpg"}}
+	lpGas := orNode{
+		clauseNode{&term{Text: "lp", Terms: []string{"lp"}}, &term{Text: "gas", Terms: []string{"gas"}}},
+		&term{Text: "propane", Terms: []string{"propan"}},
+		&phrase{Text: "liquefied petroleum gas", Terms: [][]string{{"liquefi"}, {"petroleum"}, {"gas"}}},
+	}
+
+	tests := []struct {
+		Query string
+		Node  node
+	}{
+		{"propane", propane},
+		{"LPG", &term{Text: "lpg", Terms: []string{"lpg", "propan"}}},
+		{"hank lp gas", clauseNode{hank, lpGas}},
+		{"LP Gas hank", clauseNode{lpGas, hank}},
+		{
+			"title:propane",
+			&term{Text: "propane", Field: "title", Terms: []string{"propan", "lpg"}},
+		},
+		{
+			"lp gas AND hank",
+			clauseNode{&term{Text: "lp", Terms: []string{"lp"}}, andNode{&term{Text: "gas", Terms: []string{"gas"}}, hank}},
+		},
+		{"lp* gas", clauseNode{
+			&wildcard{term: term{Text: "lp*"}, Patterns: []string{"lp*"}},
+			&term{Text: "gas", Terms: []string{"gas"}},
+		}},
+		{"propane~1", &fuzzy{term: term{Text: "propane"}, Words: []string{"propan"}, Distance: 1}},
+	}
+
+	for _, test := range tests {
+		n, err := parseQuery(test.Query)
+		assert.NoError(t, err, test.Query)
+		assert.Equal(t, test.Node, n, test.Query)
+	}
+}
+
+func TestQuery_parseQueryError(t *testing.T) {
+	tests := []struct {
+		Query  string
+		Offset int
+	}{
+		{"hank AND", 5},
+		{"hank OR OR beer", 5},
+		{"AND hank", 0},
+		{"(hank beer", 0},
+		{"hank (beer))", 11},
+		{`hank "king hill`, 5},
+		{"hank ()", 5},
+		{"NOT hank", 0},
+		{"-hank -beer", 0},
+		{"hank NEAR/5", 5},
+		{"NEAR/5 hank", 0},
+		{"hank NEAR/5 propan*", 12},
+		{"hank NEAR/99999999999999999999 propane", 5},
+		{"well-known NEAR/5 hank", 0},
+		{"hank title:", 5},
+		{"title:-hank", 0},
+		{"hank *ane", 5},
+		{"?ank", 0},
+	}
+
+	for _, test := range tests {
+		n, err := parseQuery(test.Query)
+		assert.Nil(t, n, test.Query)
+		if assert.IsType(t, &QueryError{}, err, test.Query) {
+			assert.Equal(t, test.Offset, err.(*QueryError).Offset, test.Query)
+			assert.Equal(t, test.Query, err.(*QueryError).Query, test.Query)
+		}
+	}
+}
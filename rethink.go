@@ -0,0 +1,559 @@
+package wally
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	rdb "github.com/dancannon/gorethink"
+)
+
+// ErrDocumentNotFound is returned when a document does not exist in a Store.
+var ErrDocumentNotFound = errors.New("document not found")
+
+// RethinkStore is a Store backed by RethinkDB, documents and indexes are kept
+// in separate tables with secondary indexes on the word and document of each
+// index. The distinct words, the length of each document and the collection's
+// totals are kept in their own tables, updated with every write to the
+// indexes.
+type RethinkStore struct {
+	Session       *rdb.Session
+	Database      string
+	DocumentTable string
+	IndexTable    string
+	TermTable     string
+	LengthTable   string
+	StatsTable    string
+
+	// dictionary holds the words of the term table, it's loaded once and
+	// then synced with the rows changed since the last change seen, synced.
+	mu         sync.Mutex
+	dictionary *trie
+	generation string
+	synced     time.Time
+}
+
+// collectionStats is the ID of the row of the stats table holding the number
+// of documents with indexes and the total of their lengths.
+const collectionStats = "collection"
+
+// termGeneration is the ID of the row of the stats table holding the term
+// table's generation, Rebuild gives it a new one when the table is emptied so
+// a dictionary synced with the old rows is loaded again.
+const termGeneration = "terms"
+
+// termSyncWindow is how far before the last change seen a dictionary is synced
+// from, a write that started earlier can be stamped with an earlier time but
+// finish later.
+const termSyncWindow = 5 * time.Second
+
+// NewRethinkStore returns a RethinkStore for an open session, the database and
+// table names are taken from the configuration.
+func NewRethinkStore(session *rdb.Session, c *Config) *RethinkStore {
+	return &RethinkStore{
+		Session:       session,
+		Database:      c.Database.Name,
+		DocumentTable: c.Tables.DocumentTable,
+		IndexTable:    c.Tables.IndexTable,
+		TermTable:     c.Tables.TermTable,
+		LengthTable:   c.Tables.LengthTable,
+		StatsTable:    c.Tables.StatsTable,
+	}
+}
+
+func (s *RethinkStore) documents() rdb.Term {
+	return rdb.DB(s.Database).Table(s.DocumentTable)
+}
+
+func (s *RethinkStore) indexes() rdb.Term {
+	return rdb.DB(s.Database).Table(s.IndexTable)
+}
+
+func (s *RethinkStore) terms() rdb.Term {
+	return rdb.DB(s.Database).Table(s.TermTable)
+}
+
+func (s *RethinkStore) lengths() rdb.Term {
+	return rdb.DB(s.Database).Table(s.LengthTable)
+}
+
+func (s *RethinkStore) stats() rdb.Term {
+	return rdb.DB(s.Database).Table(s.StatsTable)
+}
+
+func writeError(res rdb.WriteResponse, err error) error {
+	if err != nil {
+		return err
+	}
+	if res.Errors > 0 {
+		return errors.New(res.FirstError)
+	}
+	return nil
+}
+
+// PutDocument inserts a document into the document table.
+func (s *RethinkStore) PutDocument(doc *Document) error {
+	return writeError(s.documents().Insert(doc).RunWrite(s.Session))
+}
+
+// GetDocument fetches a document by its primary key.
+func (s *RethinkStore) GetDocument(id string) (*Document, error) {
+	res, err := s.documents().Get(id).Run(s.Session)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	if res.IsNil() {
+		return nil, ErrDocumentNotFound
+	}
+
+	doc := new(Document)
+	if err := res.One(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// HasDocuments looks up the documents by their primary keys, reporting which
+// exist.
+func (s *RethinkStore) HasDocuments(ids []string) (map[string]bool, error) {
+	found := map[string]bool{}
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	res, err := s.documents().GetAll(rdb.Args(ids)).Field("id").Run(s.Session)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	existing := []string{}
+	if err := res.All(&existing); err != nil {
+		return nil, err
+	}
+	for _, id := range existing {
+		found[id] = true
+	}
+	return found, nil
+}
+
+// DeleteDocument deletes a document's indexes using the secondary index on
+// document_id and then the document by its primary key.
+func (s *RethinkStore) DeleteDocument(id string) error {
+	if err := s.writeIndexes(s.indexes().GetAllByIndex("document_id", id).Delete(rdb.DeleteOpts{ReturnChanges: true})); err != nil {
+		return err
+	}
+	return writeError(s.documents().Get(id).Delete().RunWrite(s.Session))
+}
+
+// DeletePrefix deletes the indexes of every document whose ID starts with
+// prefix using the secondary index on document_id, and then the documents by
+// their primary keys.
+func (s *RethinkStore) DeletePrefix(prefix string) (int, error) {
+	if prefix == "" {
+		return 0, ErrEmptyPrefix
+	}
+
+	upper := prefix + string(utf8.MaxRune)
+	if err := s.writeIndexes(s.indexes().Between(prefix, upper, rdb.BetweenOpts{Index: "document_id"}).
+		Delete(rdb.DeleteOpts{ReturnChanges: true})); err != nil {
+		return 0, err
+	}
+
+	res, err := s.documents().Between(prefix, upper).Delete().RunWrite(s.Session)
+	if err := writeError(res, err); err != nil {
+		return 0, err
+	}
+	return res.Deleted, nil
+}
+
+// ReplaceDocument replaces the document and writes its indexes over any with
+// the same ID, then deletes the document's indexes that weren't rewritten.
+// RethinkDB doesn't have transactions, so until then a search can find the
+// document by a word it no longer contains, but never misses a word it does.
+func (s *RethinkStore) ReplaceDocument(doc *Document, indexes []Index) error {
+	if err := writeError(s.documents().Insert(doc, rdb.InsertOpts{Conflict: "replace"}).RunWrite(s.Session)); err != nil {
+		return err
+	}
+
+	ids := make([]string, len(indexes))
+	for i, index := range indexes {
+		ids[i] = index.ID
+	}
+	if len(indexes) > 0 {
+		replace := rdb.InsertOpts{Conflict: "replace", ReturnChanges: true}
+		if err := s.writeIndexes(s.indexes().Insert(indexes, replace)); err != nil {
+			return err
+		}
+	}
+
+	return s.writeIndexes(s.indexes().GetAllByIndex("document_id", doc.ID).
+		Filter(func(index rdb.Term) rdb.Term { return rdb.Expr(ids).Contains(index.Field("id")).Not() }).
+		Delete(rdb.DeleteOpts{ReturnChanges: true}))
+}
+
+// PutIndexes inserts indexes into the index table in a single write.
+func (s *RethinkStore) PutIndexes(indexes []Index) error {
+	return s.writeIndexes(s.indexes().Insert(indexes, rdb.InsertOpts{ReturnChanges: true}))
+}
+
+// writeIndexes runs a write to the index table that returns its changes and
+// then updates the counts kept from the indexes that were added and removed.
+// Rows are counted even when some of the write fails, like an insert with a
+// duplicate, and the write's first error is reported afterwards.
+func (s *RethinkStore) writeIndexes(t rdb.Term) error {
+	res, err := t.RunWrite(s.Session)
+	if err != nil {
+		return err
+	}
+	if err := s.count(res.Changes); err != nil {
+		return err
+	}
+	return writeError(res, nil)
+}
+
+// count adds the indexes each change added to the counts and takes away those
+// it removed, an index that was replaced is taken away and added again.
+func (s *RethinkStore) count(changes []rdb.ChangeResponse) error {
+	words := map[string]int64{}
+	lengths := map[string]int64{}
+	var total int64
+	for _, change := range changes {
+		if index, ok := changedIndex(change.OldValue); ok {
+			words[index.Word]--
+			lengths[index.DocumentID] -= index.Count
+			total -= index.Count
+		}
+		if index, ok := changedIndex(change.NewValue); ok {
+			words[index.Word]++
+			lengths[index.DocumentID] += index.Count
+			total += index.Count
+		}
+	}
+
+	if err := s.countTerms(words); err != nil {
+		return err
+	}
+	added, removed, err := s.addCounts(s.lengths(), "length", lengths)
+	if err != nil {
+		return err
+	}
+
+	if added == removed && total == 0 {
+		return nil
+	}
+	return writeError(s.stats().Insert(map[string]interface{}{
+		"id":           collectionStats,
+		"documents":    added - removed,
+		"total_length": total,
+	}, rdb.InsertOpts{Conflict: sumFields("documents", "total_length")}).RunWrite(s.Session))
+}
+
+// countTerms adds deltas to the number of documents each word is found in and
+// stamps the rows with the time they changed, using the secondary index on
+// changed a dictionary only has to read the rows changed since it was synced.
+// A word found in no documents keeps its row, with a count of 0, so the
+// dictionary learns it's gone.
+func (s *RethinkStore) countTerms(deltas map[string]int64) error {
+	rows := []map[string]interface{}{}
+	for word, delta := range deltas {
+		if delta != 0 {
+			rows = append(rows, map[string]interface{}{"id": word, "documents": delta, "changed": rdb.Now()})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return writeError(s.terms().Insert(rows, rdb.InsertOpts{Conflict: sumFields("documents")}).RunWrite(s.Session))
+}
+
+// addCounts adds deltas to field of the rows in table with the same IDs,
+// creating missing rows and deleting those left with nothing to count. The
+// number of rows created and deleted is returned. Each row is updated
+// atomically so concurrent writes don't lose each other's counts.
+func (s *RethinkStore) addCounts(table rdb.Term, field string, deltas map[string]int64) (int, int, error) {
+	rows := []map[string]interface{}{}
+	emptied := []string{}
+	for id, delta := range deltas {
+		if delta == 0 {
+			continue
+		}
+		rows = append(rows, map[string]interface{}{"id": id, field: delta})
+		if delta < 0 {
+			emptied = append(emptied, id)
+		}
+	}
+	if len(rows) == 0 {
+		return 0, 0, nil
+	}
+
+	res, err := table.Insert(rows, rdb.InsertOpts{ReturnChanges: true, Conflict: sumFields(field)}).RunWrite(s.Session)
+	if err := writeError(res, err); err != nil {
+		return 0, 0, err
+	}
+	added := 0
+	for _, change := range res.Changes {
+		if change.OldValue == nil {
+			added++
+		}
+	}
+
+	if len(emptied) == 0 {
+		return added, 0, nil
+	}
+	res, err = table.GetAll(rdb.Args(emptied)).Replace(func(row rdb.Term) interface{} {
+		return rdb.Branch(row.Field(field).Gt(0), row, nil)
+	}, rdb.ReplaceOpts{ReturnChanges: true}).RunWrite(s.Session)
+	if err := writeError(res, err); err != nil {
+		return 0, 0, err
+	}
+	removed := 0
+	for _, change := range res.Changes {
+		if change.NewValue == nil {
+			removed++
+		}
+	}
+	return added, removed, nil
+}
+
+// sumFields resolves an insert that conflicts with an existing row by adding
+// the new row's fields to the existing row's, any other fields of the new row
+// replace the existing row's.
+func sumFields(fields ...string) func(id, oldRow, newRow rdb.Term) interface{} {
+	return func(id, oldRow, newRow rdb.Term) interface{} {
+		sums := map[string]interface{}{}
+		for _, field := range fields {
+			sums[field] = oldRow.Field(field).Default(0).Add(newRow.Field(field))
+		}
+		return oldRow.Merge(newRow).Merge(sums)
+	}
+}
+
+// changedIndex reads the index a change returned by RethinkDB holds, a change
+// that added a row has no old value and one that removed a row no new value.
+func changedIndex(v interface{}) (Index, bool) {
+	row, ok := v.(map[string]interface{})
+	if !ok {
+		return Index{}, false
+	}
+
+	index := Index{}
+	index.Word, _ = row["word"].(string)
+	index.DocumentID, _ = row["document_id"].(string)
+	switch count := row["count"].(type) {
+	case float64:
+		index.Count = int64(count)
+	case int64:
+		index.Count = count
+	case int:
+		index.Count = int64(count)
+	}
+	return index, true
+}
+
+// GetIndexes looks up indexes using the secondary index on word.
+func (s *RethinkStore) GetIndexes(words []string) ([]Index, error) {
+	indexes := []Index{}
+	if len(words) == 0 {
+		return indexes, nil
+	}
+
+	res, err := s.indexes().GetAllByIndex("word", rdb.Args(words)).Run(s.Session)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	if err := res.All(&indexes); err != nil {
+		return nil, err
+	}
+	return indexes, nil
+}
+
+// CountIndexes counts indexes using the secondary index on word.
+func (s *RethinkStore) CountIndexes(words []string) (int64, error) {
+	if len(words) == 0 {
+		return 0, nil
+	}
+
+	res, err := s.indexes().GetAllByIndex("word", rdb.Args(words)).Count().Run(s.Session)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Close()
+
+	var count int64
+	if err := res.One(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Words returns the words starting with prefix from the dictionary of the term
+// table, in order.
+func (s *RethinkStore) Words(prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.syncDictionary(); err != nil {
+		return nil, err
+	}
+	return s.dictionary.withPrefix(prefix), nil
+}
+
+// FuzzyWords walks the dictionary of the term table to find the words starting
+// with prefix within distance edits of word.
+func (s *RethinkStore) FuzzyWords(prefix, word string, distance int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.syncDictionary(); err != nil {
+		return nil, err
+	}
+	return s.dictionary.fuzzy(prefix, word, distance), nil
+}
+
+// syncDictionary brings the trie of the term table's words up to date, only
+// the rows changed since it was last synced are read unless the table has been
+// emptied since, when it's loaded again. The caller holds s.mu.
+func (s *RethinkStore) syncDictionary() error {
+	var generation string
+	if err := s.one(s.stats().Get(termGeneration).Field("generation").Default(""), &generation); err != nil {
+		return err
+	}
+
+	t, synced := s.dictionary, s.synced
+	var from interface{} = rdb.MinVal
+	if !synced.IsZero() {
+		from = synced.Add(-termSyncWindow)
+	}
+	query := s.terms().Between(from, rdb.MaxVal, rdb.BetweenOpts{Index: "changed"})
+	if t == nil || generation != s.generation {
+		t, synced = newTrie(), time.Time{}
+		query = s.terms()
+	}
+
+	res, err := query.Run(s.Session)
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	rows := []struct {
+		Word      string    `gorethink:"id"`
+		Documents int64     `gorethink:"documents"`
+		Changed   time.Time `gorethink:"changed"`
+	}{}
+	if err := res.All(&rows); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		t.set(row.Word, row.Documents)
+		if row.Changed.After(synced) {
+			synced = row.Changed
+		}
+	}
+	s.dictionary, s.generation, s.synced = t, generation, synced
+	return nil
+}
+
+// Completions returns the words starting with prefix found in the most
+// documents from the dictionary of the term table, which holds the number of
+// documents each is found in.
+func (s *RethinkStore) Completions(prefix string, n int) ([]Completion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.syncDictionary(); err != nil {
+		return nil, err
+	}
+	return s.dictionary.top(prefix, n, false), nil
+}
+
+// Stats reads the collection's totals from its row of the stats table and the
+// lengths of the documents from the length table, both kept up to date as
+// indexes are written.
+func (s *RethinkStore) Stats(documentIDs []string) (*CollectionStats, error) {
+	stats := &CollectionStats{Lengths: map[string]int64{}}
+
+	totals := struct {
+		Documents   int64 `gorethink:"documents"`
+		TotalLength int64 `gorethink:"total_length"`
+	}{}
+	if err := s.one(s.stats().Get(collectionStats), &totals); err != nil {
+		return nil, err
+	}
+	stats.Documents, stats.TotalLength = totals.Documents, totals.TotalLength
+
+	if len(documentIDs) == 0 {
+		return stats, nil
+	}
+
+	res, err := s.lengths().GetAll(rdb.Args(documentIDs)).Run(s.Session)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	lengths := []struct {
+		ID     string `gorethink:"id"`
+		Length int64  `gorethink:"length"`
+	}{}
+	if err := res.All(&lengths); err != nil {
+		return nil, err
+	}
+	for _, l := range lengths {
+		stats.Lengths[l.ID] = l.Length
+	}
+	return stats, nil
+}
+
+// one runs a query returning a single value and decodes it into v, v is left
+// as it is when the value is null.
+func (s *RethinkStore) one(t rdb.Term, v interface{}) error {
+	res, err := t.Run(s.Session)
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	if res.IsNil() {
+		return nil
+	}
+	return res.One(v)
+}
+
+// Rebuild creates the tables, the secondary indexes on word and document_id
+// and the one on changed of the term table if they are missing, and then
+// empties every table. The term table is given a new generation.
+func (s *RethinkStore) Rebuild() error {
+	// These fail when the tables or index already exist, which is fine.
+	for _, table := range []string{s.DocumentTable, s.IndexTable, s.TermTable, s.LengthTable, s.StatsTable} {
+		rdb.DB(s.Database).TableCreate(table).Exec(s.Session)
+	}
+	s.indexes().IndexCreate("word").Exec(s.Session)
+	s.indexes().IndexCreate("document_id").Exec(s.Session)
+	s.terms().IndexCreate("changed").Exec(s.Session)
+
+	opts := rdb.DeleteOpts{
+		Durability:    "soft",
+		ReturnChanges: false,
+	}
+	for _, table := range []rdb.Term{s.documents(), s.indexes(), s.terms(), s.lengths(), s.stats()} {
+		if err := table.Delete(opts).Exec(s.Session); err != nil {
+			return err
+		}
+	}
+	if err := s.stats().Insert(map[string]interface{}{"id": termGeneration, "generation": rdb.UUID()}).Exec(s.Session); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.dictionary = nil
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,290 @@
+package wally
+
+import (
+	"os"
+	"testing"
+
+	rdb "github.com/dancannon/gorethink"
+	"github.com/stretchr/testify/assert"
+)
+
+// rethinkStore connects to the RethinkDB at RETHINKDB_URL and returns an empty
+// store, the test is skipped when no database is configured.
+func rethinkStore(t *testing.T) *RethinkStore {
+	url := os.Getenv("RETHINKDB_URL")
+	if url == "" {
+		t.Skip("RETHINKDB_URL is not set")
+	}
+
+	session, err := rdb.Connect(rdb.ConnectOpts{
+		Address:  url,
+		Database: "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb.DBCreate(Conf.Database.Name).Exec(session)
+
+	s := NewRethinkStore(session, Conf)
+	if err := s.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRethinkStore_Rebuild(t *testing.T) {
+	s := rethinkStore(t)
+
+	res, err := rdb.DB(s.Database).TableList().Run(s.Session)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	var response []interface{}
+	err = res.All(&response)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	assert.Equal(t, len(response), 5)
+}
+
+func TestRethinkStore_PutDocument(t *testing.T) {
+	s := rethinkStore(t)
+	defer s.Rebuild()
+
+	doc1 := Document{ID: "1"}
+	doc2 := Document{ID: "1"}
+
+	assert.NoError(t, doc1.Put(s))
+	assert.Error(t, doc2.Put(s))
+}
+
+func TestRethinkStore_GetDocument(t *testing.T) {
+	s := rethinkStore(t)
+	defer s.Rebuild()
+
+	doc := Document{ID: "http://example.com", Title: "Example"}
+	assert.NoError(t, doc.Put(s))
+
+	d, err := s.GetDocument("http://example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, d.Title, "Example")
+
+	_, err = s.GetDocument("http://example.org")
+	assert.Equal(t, err, ErrDocumentNotFound)
+}
+
+func TestRethinkStore_DeleteDocument(t *testing.T) {
+	s := rethinkStore(t)
+	defer s.Rebuild()
+
+	doc := Document{ID: "http://example.com", Content: "Hank sells propane"}
+	assert.NoError(t, doc.Upsert(s))
+
+	assert.NoError(t, s.DeleteDocument(doc.ID))
+
+	_, err := s.GetDocument(doc.ID)
+	assert.Equal(t, err, ErrDocumentNotFound)
+
+	count, err := s.CountIndexes([]string{"hank", "sell", "propan"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestRethinkStore_DeletePrefix(t *testing.T) {
+	s := rethinkStore(t)
+	defer s.Rebuild()
+
+	for _, id := range []string{"http://example.com/a", "http://example.com/b", "http://example.org"} {
+		doc := &Document{ID: id, Content: "Hank sells propane"}
+		assert.NoError(t, doc.Upsert(s))
+	}
+
+	n, err := s.DeletePrefix("http://example.com/")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	count, err := s.CountIndexes([]string{"hank"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	_, err = s.DeletePrefix("")
+	assert.Equal(t, ErrEmptyPrefix, err)
+	_, err = s.GetDocument("http://example.org")
+	assert.NoError(t, err)
+}
+
+func TestRethinkStore_GetIndexes(t *testing.T) {
+	s := rethinkStore(t)
+	defer s.Rebuild()
+
+	indexes := Indexer("hello world hello again", "1")
+	assert.NoError(t, IndexBatchPut(s, indexes))
+
+	i, err := s.GetIndexes([]string{"hello"})
+	assert.NoError(t, err)
+	assert.Equal(t, len(i), 1)
+
+	count, err := s.CountIndexes([]string{"hello", "again"})
+	assert.NoError(t, err)
+	assert.Equal(t, count, int64(2))
+}
+
+func TestRethinkStore_Stats(t *testing.T) {
+	s := rethinkStore(t)
+	defer s.Rebuild()
+
+	assert.NoError(t, IndexBatchPut(s, Indexer("hello world hello again", "1")))
+	assert.NoError(t, IndexBatchPut(s, Indexer("hello", "2")))
+
+	// Duplicates aren't counted again
+	assert.Error(t, IndexBatchPut(s, Indexer("hello", "2")))
+
+	stats, err := s.Stats([]string{"1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Documents)
+	assert.Equal(t, int64(5), stats.TotalLength)
+	assert.Equal(t, map[string]int64{"1": 4}, stats.Lengths)
+
+	doc := &Document{ID: "1", Content: "hello"}
+	assert.NoError(t, doc.Upsert(s))
+	stats, err = s.Stats([]string{"1", "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Documents)
+	assert.Equal(t, int64(2), stats.TotalLength)
+	assert.Equal(t, map[string]int64{"1": 1, "2": 1}, stats.Lengths)
+
+	assert.NoError(t, s.DeleteDocument("2"))
+	stats, err = s.Stats([]string{"2"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Documents)
+	assert.Equal(t, int64(1), stats.TotalLength)
+	assert.Equal(t, map[string]int64{}, stats.Lengths)
+
+	_, err = s.DeletePrefix("1")
+	assert.NoError(t, err)
+	stats, err = s.Stats(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Documents)
+	assert.Equal(t, int64(0), stats.TotalLength)
+}
+
+func TestRethinkStore_ReplaceDocument(t *testing.T) {
+	s := rethinkStore(t)
+	defer s.Rebuild()
+
+	doc := &Document{ID: "1", Content: "Hank sells propane"}
+	assert.NoError(t, doc.Upsert(s))
+
+	doc.Content = "Hank drinks beer"
+	assert.NoError(t, doc.Upsert(s))
+
+	d, err := s.GetDocument("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hank drinks beer", d.Content)
+
+	count, err := s.CountIndexes([]string{"hank", "sell", "propan", "drink", "beer"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestRethinkStore_Words(t *testing.T) {
+	s := rethinkStore(t)
+	defer s.Rebuild()
+
+	assert.NoError(t, IndexBatchPut(s, Indexer("propane props hank", "1")))
+	assert.NoError(t, IndexBatchPut(s, Indexer("propane", "2")))
+
+	words, err := s.Words("prop")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prop", "propan"}, words)
+
+	words, err = s.FuzzyWords("", "porpan", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"propan"}, words)
+
+	completions, err := s.Completions("pro", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Completion{{"propan", 2}, {"prop", 1}}, completions)
+
+	assert.NoError(t, s.DeleteDocument("2"))
+
+	completions, err = s.Completions("pro", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Completion{{"prop", 1}}, completions)
+}
+
+func TestRethinkStore_WordsDeleted(t *testing.T) {
+	s := rethinkStore(t)
+	defer s.Rebuild()
+
+	assert.NoError(t, (&Document{ID: "1", Content: "Hank sells propane"}).Upsert(s))
+	assert.NoError(t, (&Document{ID: "2", Content: "Hank drinks beer"}).Upsert(s))
+
+	words, err := s.FuzzyWords("", "porpan", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"propan"}, words)
+
+	assert.NoError(t, s.DeleteDocument("1"))
+
+	words, err = s.FuzzyWords("", "porpan", 1)
+	assert.NoError(t, err)
+	assert.Empty(t, words)
+
+	words, err = s.FuzzyWords("", "hnak", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hank"}, words)
+
+	assert.NoError(t, (&Document{ID: "2", Content: "Hank sells propane"}).Upsert(s))
+
+	words, err = s.Words("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hank", "propan", "sell"}, words)
+
+	words, err = s.FuzzyWords("", "bere", 2)
+	assert.NoError(t, err)
+	assert.Empty(t, words)
+}
+
+func TestRethinkStore_WordsRebuilt(t *testing.T) {
+	s := rethinkStore(t)
+	defer s.Rebuild()
+
+	// Another process sharing the database keeps its own dictionary
+	other := NewRethinkStore(s.Session, Conf)
+
+	assert.NoError(t, (&Document{ID: "1", Content: "Hank sells propane"}).Upsert(s))
+	words, err := other.Words("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hank", "propan", "sell"}, words)
+
+	assert.NoError(t, (&Document{ID: "2", Content: "Hank drinks beer"}).Upsert(s))
+	words, err = other.Words("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"beer", "drink", "hank", "propan", "sell"}, words)
+
+	assert.NoError(t, s.Rebuild())
+	assert.NoError(t, (&Document{ID: "3", Content: "Dale smokes"}).Upsert(s))
+
+	words, err = other.Words("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dale", "smoke"}, words)
+}
+
+func TestRethinkStore_NoWordIndex(t *testing.T) {
+	s := rethinkStore(t)
+	defer s.Rebuild()
+
+	s.indexes().IndexDrop("word").Exec(s.Session)
+	defer s.indexes().IndexCreate("word").Exec(s.Session)
+
+	r := new(Results)
+	err := r.NumberOfResults([]string{"example"}, s)
+	assert.Equal(t, r.Count, int64(0))
+	assert.Error(t, err)
+
+	_, err = Search("hello", s, 1)
+	assert.Error(t, err)
+}
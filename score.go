@@ -0,0 +1,57 @@
+package wally
+
+import "math"
+
+// Scoring models, see Scoring.
+const (
+	BM25  = "bm25"
+	TFIDF = "tfidf"
+)
+
+// scorer works out how much each index adds to its document's score, using
+// statistics about the whole collection. Frequencies holds the number of
+// documents each word was found in.
+type scorer struct {
+	Scoring     Scoring
+	Stats       *CollectionStats
+	Frequencies map[string]int64
+}
+
+// score returns the score an index gives its document, with Okapi BM25
+// (https://en.wikipedia.org/wiki/Okapi_BM25) by default or TF-IDF. Rare words
+// score higher than common ones, BM25 also scores a word found in a short
+// document above the same word found as often in a long one. The score is
+// boosted by the weight given to the index's field.
+func (s scorer) score(index Index) float64 {
+	return s.Scoring.boost(index.Field) * s.termScore(index)
+}
+
+func (s scorer) termScore(index Index) float64 {
+	tf := float64(index.Count)
+	if tf <= 0 {
+		return 0
+	}
+
+	n := float64(s.Stats.Documents)
+	df := float64(s.Frequencies[index.Word])
+	if df < 1 {
+		df = 1
+	}
+	if n < df {
+		n = df
+	}
+
+	if s.Scoring.Model == TFIDF {
+		return (1 + math.Log(tf)) * math.Log(1+n/df)
+	}
+
+	avgdl := s.Stats.AverageLength()
+	dl := float64(s.Stats.Lengths[index.DocumentID])
+	if dl == 0 || avgdl == 0 {
+		dl, avgdl = 1, 1
+	}
+
+	k1, b := s.Scoring.K1, s.Scoring.B
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	return idf * tf * (k1 + 1) / (tf + k1*(1-b+b*dl/avgdl))
+}
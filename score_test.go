@@ -0,0 +1,57 @@
+package wally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScore_BM25(t *testing.T) {
+	s := scorer{
+		Scoring: DefaultConfig().Scoring,
+		Stats: &CollectionStats{
+			Documents:   10,
+			TotalLength: 1000,
+			Lengths:     map[string]int64{"short": 50, "long": 400},
+		},
+		Frequencies: map[string]int64{"rare": 1, "common": 9},
+	}
+
+	rare := s.score(Index{Word: "rare", Count: 1, DocumentID: "short"})
+	common := s.score(Index{Word: "common", Count: 1, DocumentID: "short"})
+	assert.True(t, rare > common)
+
+	short := s.score(Index{Word: "common", Count: 2, DocumentID: "short"})
+	long := s.score(Index{Word: "common", Count: 2, DocumentID: "long"})
+	assert.True(t, short > long)
+
+	// Repeating a word adds less each time
+	once := s.score(Index{Word: "rare", Count: 1, DocumentID: "short"})
+	twice := s.score(Index{Word: "rare", Count: 2, DocumentID: "short"})
+	thrice := s.score(Index{Word: "rare", Count: 3, DocumentID: "short"})
+	assert.True(t, twice-once > thrice-twice)
+
+	assert.InDelta(t, 2.5048, rare, 0.0001)
+	assert.Equal(t, 0.0, s.score(Index{Word: "rare", DocumentID: "short"}))
+}
+
+func TestScore_TFIDF(t *testing.T) {
+	s := scorer{
+		Scoring: Scoring{Model: TFIDF},
+		Stats: &CollectionStats{
+			Documents:   10,
+			TotalLength: 1000,
+			Lengths:     map[string]int64{"short": 50, "long": 400},
+		},
+		Frequencies: map[string]int64{"rare": 1, "common": 9},
+	}
+
+	rare := s.score(Index{Word: "rare", Count: 1, DocumentID: "short"})
+	common := s.score(Index{Word: "common", Count: 1, DocumentID: "short"})
+	assert.True(t, rare > common)
+
+	// Document length makes no difference
+	short := s.score(Index{Word: "common", Count: 2, DocumentID: "short"})
+	long := s.score(Index{Word: "common", Count: 2, DocumentID: "long"})
+	assert.Equal(t, short, long)
+}
@@ -1,34 +1,56 @@
 package wally
 
 import (
-	"strings"
+	"sort"
 	"time"
-
-	rdb "github.com/dancannon/gorethink"
 )
 
-// Query is one result in a successful search.
+// Query is one result in a successful search, there's one for each document
+// found. Index is the document's best matching index, Indexes holds every index
+// that matched it, Terms the words of those indexes and Score is the combined
+// score they give the document. Snippet is the part of the document's content
+// that best matches the query with the matches highlighted, see Snippet.
 type Query struct {
 	Document
 	Index
+	Indexes []Index
+	Terms   []string
+	Score   float64
+	Snippet string
 }
 
-// Results contains the search results of a successful Search().
+// Results contains the search results of a successful Search(). When nothing
+// is found Suggestion may hold the query with its misspelt words corrected,
+// which does find something.
 type Results struct {
-	Count   int64
-	Results []Query
-	Time    float64
+	Count      int64
+	Results    []Query
+	Time       float64
+	Suggestion string
 }
 
-func (r *Results) NumberOfResults(keys []string, session *rdb.Session) error {
-	var count int
-	res, err := rdb.DB(Conf.Database.Name).Table(Conf.Tables.IndexTable).GetAllByIndex("word", rdb.Args(keys)).Count().Run(session)
+// NumberOfResults sets Count to the number of stored documents with an index
+// for any of keys, the same way Search counts the documents it finds.
+func (r *Results) NumberOfResults(keys []string, store Store) error {
+	indexes, err := store.GetIndexes(keys)
 	if err != nil {
 		return err
 	}
 
-	res.One(&count)
-	r.Count = int64(count)
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, index := range indexes {
+		if !seen[index.DocumentID] {
+			seen[index.DocumentID] = true
+			ids = append(ids, index.DocumentID)
+		}
+	}
+
+	found, err := store.HasDocuments(ids)
+	if err != nil {
+		return err
+	}
+	r.Count = int64(len(found))
 	return nil
 }
 
@@ -39,12 +61,121 @@ func parsePageNumber(page int) uint {
 	return uint(page)
 }
 
+// byCount orders indexes by their count, highest first, ties are broken by ID
+// so that paging through results is stable.
+type byCount []Index
+
+func (b byCount) Len() int      { return len(b) }
+func (b byCount) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byCount) Less(i, j int) bool {
+	if b[i].Count != b[j].Count {
+		return b[i].Count > b[j].Count
+	}
+	return b[i].ID < b[j].ID
+}
+
+// byScore orders results by their score, highest first, ties are broken by
+// document ID so that paging through results is stable.
+type byScore []Query
+
+func (b byScore) Len() int      { return len(b) }
+func (b byScore) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byScore) Less(i, j int) bool {
+	if b[i].Score != b[j].Score {
+		return b[i].Score > b[j].Score
+	}
+	return b[i].DocumentID < b[j].DocumentID
+}
+
+// byIndexScore orders indexes by the score they give their documents.
+type byIndexScore struct {
+	indexes []Index
+	scores  map[string]float64
+}
+
+func (b byIndexScore) Len() int      { return len(b.indexes) }
+func (b byIndexScore) Swap(i, j int) { b.indexes[i], b.indexes[j] = b.indexes[j], b.indexes[i] }
+func (b byIndexScore) Less(i, j int) bool {
+	x, y := b.indexes[i], b.indexes[j]
+	if b.scores[x.ID] != b.scores[y.ID] {
+		return b.scores[x.ID] > b.scores[y.ID]
+	}
+	return x.ID < y.ID
+}
+
+// mergeResults groups indexes by document, giving a result for each document
+// with its indexes ordered by score. A document's score is the total of its
+// indexes' scores.
+func mergeResults(indexes []Index, s scorer) []Query {
+	scores := map[string]float64{}
+	for _, index := range indexes {
+		scores[index.ID] = s.score(index)
+	}
+	sort.Sort(byIndexScore{indexes, scores})
+
+	results := []Query{}
+	found := map[string]int{}
+	for _, index := range indexes {
+		i, ok := found[index.DocumentID]
+		if !ok {
+			i = len(results)
+			found[index.DocumentID] = i
+			results = append(results, Query{Index: index})
+		}
+
+		q := &results[i]
+		q.Indexes = append(q.Indexes, index)
+		q.Terms = append(q.Terms, index.Word)
+		q.Score += scores[index.ID]
+	}
+
+	sort.Sort(byScore(results))
+	return results
+}
+
 // Search returns a list of results along with the time taken to run and the
-// number of results found.
-func Search(query string, session *rdb.Session, currentPage int) (*Results, error) {
+// number of results found. Documents matching any of the query's words are
+// found, words can be combined with AND, OR and NOT and grouped in
+// parentheses, +word must be in every document found and -word mustn't be in
+// any. Words in double quotes are searched for as a phrase, so "king hill" only
+// matches documents where the words are next to each other, and hank NEAR/5
+// propane matches documents where the words are no more than 5 terms apart.
+// propan* and te?as are wildcard patterns, matching words starting with
+// "propan" and words like "Texas", and rutherfrod~2 matches words no more than
+// two edits from "rutherfrod". Words are searched for in a document's
+// content, title and author, title:hill only looks in titles. Each document
+// found is given once, with every term that matched it, ranked by its score,
+// with a snippet of its content, and Count is the number of documents found.
+// When nothing is found a spelling correction is suggested using the indexed
+// words. Queries that find something are remembered for Complete when
+// Autocomplete.Queries is set. A *QueryError is returned when the query can't
+// be parsed.
+func Search(query string, store Store, currentPage int) (*Results, error) {
 	start := time.Now()
+
+	r, err := search(query, store, currentPage)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Count == 0 {
+		if r.Suggestion, err = suggest(query, store); err != nil {
+			return nil, err
+		}
+	} else if config().Autocomplete.Queries {
+		pastQueries.record(query)
+	}
+
+	r.Time = time.Since(start).Seconds()
+	return r, nil
+}
+
+func search(query string, store Store, currentPage int) (*Results, error) {
 	res := []Query{}
-	keys := strings.Split(query, " ")
+	root, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
 
 	r := new(Results)
 
@@ -53,18 +184,65 @@ func Search(query string, session *rdb.Session, currentPage int) (*Results, erro
 	lower := (page - 1) * ItemsPerPage
 	upper := page * ItemsPerPage
 
-	results, err := rdb.DB(Conf.Database.Name).Table(Conf.Tables.IndexTable).GetAllByIndex("word", rdb.Args(keys)).EqJoin("document_id", rdb.DB(Conf.Database.Name).Table(Conf.Tables.DocumentTable)).Zip().OrderBy(rdb.Desc("count")).Slice(lower, upper).Run(session)
+	indexes, frequencies, err := searchIndexes(root, store)
+	if err != nil {
+		return nil, err
+	}
+
+	documentIDs := []string{}
+	seen := map[string]bool{}
+	for _, index := range indexes {
+		if !seen[index.DocumentID] {
+			seen[index.DocumentID] = true
+			documentIDs = append(documentIDs, index.DocumentID)
+		}
+	}
+
+	stats, err := store.Stats(documentIDs)
 	if err != nil {
 		return nil, err
 	}
 
-	results.All(&res)
+	results := mergeResults(indexes, scorer{config().Scoring, stats, frequencies})
+
+	// Indexes can outlive their document, such as after a crash part way
+	// through deleting it, those results are left out so they aren't counted
+	found, err := store.HasDocuments(documentIDs)
+	if err != nil {
+		return nil, err
+	}
+	kept := results[:0]
+	for _, result := range results {
+		if found[result.DocumentID] {
+			kept = append(kept, result)
+		}
+	}
+	results = kept
+
+	if lower > uint(len(results)) {
+		lower = uint(len(results))
+	}
+	if upper > uint(len(results)) {
+		upper = uint(len(results))
+	}
+
+	terms := highlightTerms(root)
+	for _, result := range results[lower:upper] {
+		doc, err := store.GetDocument(result.DocumentID)
+		if err == ErrDocumentNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Document = *doc
+		result.Snippet = Snippet(doc.Content, doc.Language, terms)
+		res = append(res, result)
+	}
 
-	r.NumberOfResults(keys, session)
+	r.Count = int64(len(results))
 
 	r.Results = res
-	t := time.Since(start).Seconds()
-	r.Time = t
 
 	return r, nil
 }
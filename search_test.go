@@ -1,9 +1,10 @@
 package wally
 
 import (
+	"sort"
+	"strconv"
 	"testing"
 
-	rdb "github.com/dancannon/gorethink"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,6 +18,30 @@ func tearDown() {
 	ItemsPerPage = originalItemsPerPage
 }
 
+// putDocuments stores and indexes each of docs.
+func putDocuments(t *testing.T, docs []*Document) {
+	for _, doc := range docs {
+		assert.NoError(t, doc.Put(store))
+		assert.NoError(t, IndexBatchPut(store, IndexDocument(doc)))
+	}
+}
+
+// searchIDs searches for query and returns the IDs of the documents found, in
+// order.
+func searchIDs(t *testing.T, query string) []string {
+	results, err := Search(query, store, 1)
+	if !assert.NoError(t, err, query) {
+		return nil
+	}
+
+	ids := []string{}
+	for _, result := range results.Results {
+		ids = append(ids, result.DocumentID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 func SearchSetup() error {
 	d1 := Document{
 		ID:      "http://example.com",
@@ -32,22 +57,22 @@ func SearchSetup() error {
 		Content: "Save the example",
 	}
 
-	if err := d1.Put(session); err != nil {
+	if err := d1.Put(store); err != nil {
 		return err
 	}
 
-	if err := d2.Put(session); err != nil {
+	if err := d2.Put(store); err != nil {
 		return err
 	}
 
 	i1 := Indexer(d1.Content, d1.ID)
 	i2 := Indexer(d2.Content, d2.ID)
 
-	if err := IndexBatchPut(session, i1); err != nil {
+	if err := IndexBatchPut(store, i1); err != nil {
 		return err
 	}
 
-	if err := IndexBatchPut(session, i2); err != nil {
+	if err := IndexBatchPut(store, i2); err != nil {
 		return err
 	}
 
@@ -55,7 +80,7 @@ func SearchSetup() error {
 }
 
 func TestSearch_Search(t *testing.T) {
-	defer tearDbDown(session)
+	defer tearDbDown(store)
 
 	setUp(1)
 	if err := SearchSetup(); err != nil {
@@ -63,7 +88,7 @@ func TestSearch_Search(t *testing.T) {
 	}
 
 	for i := 1; i < 3; i++ {
-		results, err := Search("example", session, i)
+		results, err := Search("example", store, i)
 		assert.NoError(t, err)
 
 		assert.Equal(t, len(results.Results), 1)
@@ -71,40 +96,592 @@ func TestSearch_Search(t *testing.T) {
 	tearDown()
 }
 
-func TestSearch_SearchNumberOfResults(t *testing.T) {
-	defer tearDbDown(session)
+func TestSearch_SearchStemmed(t *testing.T) {
+	defer tearDbDown(store)
 
 	if err := SearchSetup(); err != nil {
 		t.Errorf(err.Error())
 	}
 
-	r := new(Results)
-	err := r.NumberOfResults([]string{"example"}, session)
+	results, err := Search("Examples", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, results.Count, int64(2))
 
-	assert.Equal(t, r.Count, int64(2))
+	results, err = Search("the", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, results.Count, int64(0))
+}
+
+func TestSearch_SearchFoldDiacritics(t *testing.T) {
+	defer tearDbDown(store)
+
+	Conf.Analysis.FoldDiacritics = true
+	defer func() { Conf.Analysis.FoldDiacritics = false }()
+
+	doc := NewDocument("1")
+	doc.Content = "A café in Ｔｅｘａｓ"
+	assert.NoError(t, doc.Put(store))
+	assert.NoError(t, IndexBatchPut(store, IndexDocument(doc)))
+
+	for _, query := range []string{"cafe", "CAFÉ", "texas"} {
+		results, err := Search(query, store, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), results.Count, query)
+	}
+}
+
+func TestSearch_SearchPhrase(t *testing.T) {
+	defer tearDbDown(store)
+
+	setUp(10)
+	defer tearDown()
+
+	docs := []*Document{
+		{ID: "1", Content: "Hank Hill is the star of King of the Hill"},
+		{ID: "2", Content: "The hill king ruled the hill"},
+		{ID: "3", Content: "A king lived on a hill"},
+	}
+	putDocuments(t, docs)
+
+	tests := []struct {
+		Query     string
+		Documents []string
+	}{
+		{`"king hill"`, []string{"1"}},
+		{`"King of the Hill"`, []string{"1"}},
+		{`"hill king"`, []string{"2"}},
+		{`"hank hill star king"`, []string{"1"}},
+		{`"hill hank"`, []string{}},
+		{`"the king"`, []string{"1", "2", "3"}},
+	}
+
+	for _, test := range tests {
+		results, err := Search(test.Query, store, 1)
+		assert.NoError(t, err)
+
+		ids := []string{}
+		for _, result := range results.Results {
+			ids = append(ids, result.DocumentID)
+		}
+		sort.Strings(ids)
+		assert.Equal(t, test.Documents, ids, test.Query)
+		assert.Equal(t, int64(len(test.Documents)), results.Count, test.Query)
+	}
+}
+
+func TestSearch_SearchPhraseAndWords(t *testing.T) {
+	defer tearDbDown(store)
+
+	setUp(10)
+	defer tearDown()
+
+	docs := []*Document{
+		{ID: "1", Content: "King of the Hill"},
+		{ID: "2", Content: "Strickland Propane"},
+	}
+	putDocuments(t, docs)
+
+	results, err := Search(`propane "king hill"`, store, 1)
 	assert.NoError(t, err)
+	assert.Equal(t, int64(2), results.Count)
+	assert.Len(t, results.Results, 2)
+
+	for _, result := range results.Results {
+		if result.DocumentID == "1" {
+			assert.Equal(t, "king hill", result.Word)
+			assert.Equal(t, []int{0}, result.Positions)
+		}
+	}
 }
 
-func TestSearch_SearchNumberOfResultsNoIndex(t *testing.T) {
+func TestSearch_SearchBoolean(t *testing.T) {
+	defer tearDbDown(store)
+
+	setUp(20)
+	defer tearDown()
+
+	docs := []*Document{
+		{ID: "1", Content: "Hank sells propane and propane accessories"},
+		{ID: "2", Content: "Hank drinks beer in the alley"},
+		{ID: "3", Content: "Dale sells bug spray and drinks beer"},
+		{ID: "4", Content: "King of the Hill"},
+	}
+	putDocuments(t, docs)
+
+	tests := []struct {
+		Query     string
+		Documents []string
+	}{
+		{"hank beer", []string{"1", "2", "3"}},
+		{"hank OR beer", []string{"1", "2", "3"}},
+		{"hank AND beer", []string{"2"}},
+		{"hank AND NOT beer", []string{"1"}},
+		{"beer NOT hank", []string{"3"}},
+		{"beer -hank", []string{"3"}},
+		{"+hank beer", []string{"1", "2"}},
+		{"+hank +beer", []string{"2"}},
+		{"+hank +beer -alley", []string{}},
+		{"(hank OR dale) AND beer", []string{"2", "3"}},
+		{"sells AND (propane OR spray)", []string{"1", "3"}},
+		{"sells AND NOT (propane OR alley)", []string{"3"}},
+		{`"king hill" OR propane`, []string{"1", "4"}},
+		{"beer AND NOT (NOT hank)", []string{"2"}},
+		{"the AND hank", []string{"1", "2"}},
+		{"hank AND (NOT beer OR alley)", []string{"1", "2"}},
+		{"", []string{}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Documents, searchIDs(t, test.Query), test.Query)
+	}
+
+	// Only the words that aren't excluded are results
+	results, err := Search("beer -hank", store, 1)
+	assert.NoError(t, err)
+	assert.Len(t, results.Results, 1)
+	assert.Equal(t, "beer", results.Results[0].Word)
+}
+
+func TestSearch_SearchQueryError(t *testing.T) {
+	tests := []string{
+		"hank AND",
+		"OR hank",
+		"(hank beer",
+		"hank beer)",
+		`"king hill`,
+		"()",
+		"NOT hank",
+		"-hank -beer",
+		"hank NEAR/5",
+		"hank NEAR/5 (beer)",
+	}
+
+	for _, query := range tests {
+		results, err := Search(query, store, 1)
+		assert.Nil(t, results, query)
+		assert.IsType(t, &QueryError{}, err, query)
+	}
+}
+
+func TestSearch_SearchNear(t *testing.T) {
+	defer tearDbDown(store)
+
+	setUp(10)
+	defer tearDown()
+
+	docs := []*Document{
+		{ID: "1", Content: "Hank sells propane and propane accessories"},
+		{ID: "2", Content: "Propane is sold by Hank"},
+		{ID: "3", Content: "Hank drinks beer in the alley with Dale, Bill and Boomhauer, then goes home to sell propane"},
+	}
+	putDocuments(t, docs)
+
+	tests := []struct {
+		Query     string
+		Documents []string
+	}{
+		{"hank NEAR/1 propane", []string{}},
+		{"hank NEAR/2 propane", []string{"1", "2"}},
+		{"propane NEAR/2 hank", []string{"1", "2"}},
+		{"hank NEAR/20 propane", []string{"1", "2", "3"}},
+		{"hank NEAR/2 propane beer", []string{"1", "2", "3"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Documents, searchIDs(t, test.Query), test.Query)
+	}
+
+	results, err := Search("hank NEAR/3 propane", store, 1)
+	assert.NoError(t, err)
+	for _, result := range results.Results {
+		if result.DocumentID == "1" {
+			assert.Equal(t, "hank near/3 propane", result.Word)
+			assert.Equal(t, int64(1), result.Count)
+			assert.Equal(t, []int{0}, result.Positions)
+		}
+	}
+}
+
+func TestSearch_SearchMerged(t *testing.T) {
+	defer tearDbDown(store)
+
+	setUp(10)
+	defer tearDown()
+
+	docs := []*Document{
+		{ID: "1", Content: "Hank sells propane and propane accessories"},
+		{ID: "2", Content: "Hank drinks beer"},
+		{ID: "3", Content: "Propane"},
+	}
+	putDocuments(t, docs)
+
+	results, err := Search("hank propane", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), results.Count)
+	assert.Len(t, results.Results, 3)
+
+	first := results.Results[0]
+	assert.Equal(t, "1", first.Document.ID)
+	assert.Equal(t, []string{"propan", "hank"}, first.Terms)
+	assert.Len(t, first.Indexes, 2)
+	assert.Equal(t, "propan", first.Word)
+	assert.Equal(t, "<b>Hank</b> sells <b>propane</b> and <b>propane</b> accessories", first.Snippet)
+
+	// The short document about propane beats the one mentioning Hank in
+	// passing
+	assert.Equal(t, "3", results.Results[1].Document.ID)
+	assert.Equal(t, "2", results.Results[2].Document.ID)
+	assert.Equal(t, []string{"hank"}, results.Results[2].Terms)
+	assert.True(t, first.Score > results.Results[1].Score)
+	assert.True(t, results.Results[1].Score > results.Results[2].Score)
+
+	// Pages are of documents rather than terms
+	setUp(1)
+	for page, id := range []string{"1", "3", "2"} {
+		results, err := Search("hank propane", store, page+1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), results.Count)
+		if assert.Len(t, results.Results, 1) {
+			assert.Equal(t, id, results.Results[0].Document.ID)
+		}
+	}
+}
+
+func TestSearch_SearchFields(t *testing.T) {
+	defer tearDbDown(store)
+
+	setUp(10)
+	defer tearDown()
+
+	docs := []*Document{
+		{ID: "1", Title: "King of the Hill", Author: "Mike Judge", Content: "Hank Hill sells propane"},
+		{ID: "2", Title: "Propane", Author: "Hank Hill", Content: "The judge ruled on the king's case"},
+		{ID: "3", Title: "Beer", Content: "Dale drinks beer on the hill"},
+	}
+	putDocuments(t, docs)
+
+	tests := []struct {
+		Query     string
+		Documents []string
+	}{
+		{"title:hill", []string{"1"}},
+		{"title:hill author:judge", []string{"1"}},
+		{"+title:propane", []string{"2"}},
+		{"author:hill", []string{"2"}},
+		{"content:hill", []string{"1", "3"}},
+		{`title:"king hill"`, []string{"1"}},
+		{`"king hill"`, []string{"1"}},
+		{"title:(beer OR propane)", []string{"2", "3"}},
+		{"judge -author:judge", []string{"2"}},
+		{"mike", []string{"1"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Documents, searchIDs(t, test.Query), test.Query)
+	}
+
+	// A match in a title is worth more than one in the content
+	results, err := Search("propane", store, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, results.Results, 2) {
+		assert.Equal(t, "2", results.Results[0].Document.ID)
+		assert.Equal(t, "title:propan", results.Results[0].Word)
+	}
+
+	Conf.Scoring.Boosts = map[string]float64{TitleField: 0.1}
+	defer func() { Conf.Scoring.Boosts = DefaultConfig().Scoring.Boosts }()
+
+	results, err = Search("propane", store, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, results.Results, 2) {
+		assert.Equal(t, "1", results.Results[0].Document.ID)
+	}
+}
+
+func TestSearch_SearchWildcard(t *testing.T) {
+	defer tearDbDown(store)
+
+	setUp(10)
+	defer tearDown()
+
+	docs := []*Document{
+		{ID: "1", Title: "Propane", Content: "Hank sells propane in Texas"},
+		{ID: "2", Content: "Propaganda about beer"},
+		{ID: "3", Content: "Dale has a proper texan hat"},
+	}
+	putDocuments(t, docs)
+
+	tests := []struct {
+		Query     string
+		Documents []string
+	}{
+		{"propan*", []string{"1"}},
+		{"propa*", []string{"1", "2"}},
+		{"prop*", []string{"1", "2", "3"}},
+		{"te?as", []string{"1"}},
+		{"tex*", []string{"1", "3"}},
+		{"prop* -beer", []string{"1", "3"}},
+		{"title:prop*", []string{"1"}},
+		{"+prop* +hat", []string{"3"}},
+		{"beer*", []string{"2"}},
+		{"zzz*", []string{}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Documents, searchIDs(t, test.Query), test.Query)
+	}
+
+	results, err := Search("te?as", store, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, results.Results, 1) {
+		assert.Equal(t, "Hank sells propane in <b>Texas</b>", results.Results[0].Snippet)
+	}
+
+	_, err = Search("*ane", store, 1)
+	assert.IsType(t, &QueryError{}, err)
+}
+
+func TestSearch_SearchFuzzy(t *testing.T) {
+	defer tearDbDown(store)
+
+	setUp(10)
+	defer tearDown()
+
+	docs := []*Document{
+		{ID: "1", Title: "Ernest Rutherford", Content: "Rutherford split the atom"},
+		{ID: "2", Content: "Hank sells propane"},
+		{ID: "3", Content: "Ruth drinks beer further away"},
+	}
+	putDocuments(t, docs)
+
+	tests := []struct {
+		Query     string
+		Documents []string
+	}{
+		{"rutherfrod", []string{}},
+		{"rutherfrod~2", []string{"1"}},
+		{"rutherfrod~1", []string{"1"}},
+		{"rutherfrod~0", []string{}},
+		{"rutherfrod~", []string{"1"}},
+		{"title:rutherfrod~", []string{"1"}},
+		{"author:rutherfrod~", []string{}},
+		{"porpane~1 OR rtuh~1", []string{"2", "3"}},
+		{"+beer~1 -ruth~", []string{}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Documents, searchIDs(t, test.Query), test.Query)
+	}
+
+	results, err := Search("rutherfrod~", store, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, results.Results, 1) {
+		assert.Equal(t, "<b>Rutherford</b> split the atom", results.Results[0].Snippet)
+	}
+	assert.Equal(t, "", results.Suggestion)
+
+	results, err = Search("rutherfrod", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), results.Count)
+	assert.Equal(t, "rutherford", results.Suggestion)
+}
+
+func TestSearch_SearchSynonyms(t *testing.T) {
+	defer tearDbDown(store)
+
+	setUp(10)
+	defer tearDown()
+
+	Conf.Synonyms.File = "test_data/synonyms.txt"
+	assert.NoError(t, Conf.Synonyms.Load())
+	defer func() {
+		Conf.Synonyms = DefaultConfig().Synonyms
+	}()
+
+	docs := []*Document{
+		{ID: "1", Content: "Hank sells propane and propane accessories"},
+		{ID: "2", Content: "Cheap LPG delivered"},
+		{ID: "3", Content: "Liquefied petroleum gas is heavier than air"},
+		{ID: "4", Content: "Petroleum prices and gas prices"},
+		{ID: "5", Title: "KOTH", Content: "Hank drinks beer in the alley"},
+	}
+	putDocuments(t, docs)
+
+	tests := []struct {
+		Query     string
+		Documents []string
+	}{
+		{"propane", []string{"1", "2"}},
+		{"lpg", []string{"1", "2"}},
+		{"lp gas", []string{"1", "3", "4"}},
+		{"+lp +gas", []string{}},
+		{"title:(king of the hill)", []string{"5"}},
+		{"propane -hank", []string{"2"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Documents, searchIDs(t, test.Query), test.Query)
+	}
+
+	results, err := Search("lpg", store, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, results.Results, 2) {
+		assert.Equal(t, "Hank sells <b>propane</b> and <b>propane</b> accessories", results.Results[0].Snippet)
+	}
+}
+
+func TestSearch_SearchNGrams(t *testing.T) {
+	defer tearDbDown(store)
+
+	setUp(10)
+	defer tearDown()
+
+	Conf.Analysis.NGrams = NGrams{Fields: []string{"content", "title"}, Size: 2}
+	defer func() { Conf.Analysis.NGrams = DefaultConfig().Analysis.NGrams }()
+
+	docs := []*Document{
+		{ID: "1", Title: "Propane", Content: "東京都に住んでいます"},
+		{ID: "2", Title: "Strickland", Content: "京都の寺", Author: "Hank Hill"},
+		{ID: "3", Title: "Beer", Content: "Hank sells propane and propane accessories"},
+	}
+	putDocuments(t, docs)
+
+	tests := []struct {
+		Query     string
+		Documents []string
+	}{
+		{"京都", []string{"1", "2"}},
+		{"東京都", []string{"1"}},
+		{"住む", []string{}},
+		{"opan", []string{"1", "3"}},
+		{"title:opan", []string{"1"}},
+		{"content:ccessor", []string{"3"}},
+		{"rick", []string{"2"}},
+		{"hank", []string{"2", "3"}},
+		{"author:hank", []string{"2"}},
+		{"author:han", []string{}},
+		{"n", []string{"1", "2", "3"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Documents, searchIDs(t, test.Query), test.Query)
+	}
+
+	snippets := []struct {
+		Query    string
+		Document string
+		Snippet  string
+	}{
+		{"ropan", "3", "Hank sells <b>propane</b> and <b>propane</b> accessories"},
+		{"京都", "1", "東<b>京都</b>に住んでいます"},
+		{"京都", "2", "<b>京都</b>の寺"},
+		{"content:ccessor", "3", "Hank sells propane and propane <b>accessories</b>"},
+	}
+
+	for _, test := range snippets {
+		results, err := Search(test.Query, store, 1)
+		assert.NoError(t, err, test.Query)
+
+		snippet := ""
+		for _, result := range results.Results {
+			if result.DocumentID == test.Document {
+				snippet = result.Snippet
+			}
+		}
+		assert.Equal(t, test.Snippet, snippet, test.Query)
+	}
+}
+
+func TestSearch_SearchNGramsShort(t *testing.T) {
+	defer tearDbDown(store)
+
+	setUp(100)
+	defer tearDown()
+
+	Conf.Analysis.NGrams = NGrams{Fields: []string{"content"}, Size: 2}
+	defer func() { Conf.Analysis.NGrams = DefaultConfig().Analysis.NGrams }()
+
+	// More distinct grams start with 東 than a wildcard is expanded into
+	docs := []*Document{}
+	for i := 0; i < 80; i++ {
+		docs = append(docs, &Document{ID: strconv.Itoa(i), Content: "東" + string(rune('一'+i))})
+	}
+	putDocuments(t, docs)
+
+	results, err := Search("東", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(80), results.Count)
+	assert.Len(t, results.Results, 80)
+}
+
+func TestSearch_SearchTFIDF(t *testing.T) {
+	defer tearDbDown(store)
+
+	setUp(10)
+	defer tearDown()
+
+	Conf.Scoring.Model = TFIDF
+	defer func() { Conf.Scoring.Model = BM25 }()
+
+	docs := []*Document{
+		{ID: "1", Content: "Hank Hank Hank drinks beer in the alley with Dale, Bill and Boomhauer"},
+		{ID: "2", Content: "Hank sells propane"},
+		{ID: "3", Content: "Propane"},
+	}
+	putDocuments(t, docs)
+
+	// TF-IDF doesn't mind how long the first document is
+	results, err := Search("hank", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", results.Results[0].Document.ID)
+	assert.Equal(t, "2", results.Results[1].Document.ID)
+
+	Conf.Scoring.Model = BM25
+	results, err = Search("hank", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", results.Results[0].Document.ID)
+	assert.True(t, results.Results[0].Score > 0)
+}
+
+func TestSearch_SearchNumberOfResults(t *testing.T) {
+	defer tearDbDown(store)
+
 	if err := SearchSetup(); err != nil {
 		t.Errorf(err.Error())
 	}
-	rdb.DB(Conf.Database.Name).Table(Conf.Tables.IndexTable).IndexDrop("word").Exec(session)
 
 	r := new(Results)
-	err := r.NumberOfResults([]string{"example"}, session)
+	err := r.NumberOfResults([]string{"exampl"}, store)
+
+	assert.Equal(t, r.Count, int64(2))
+	assert.NoError(t, err)
 
-	assert.Equal(t, r.Count, int64(0))
-	assert.Error(t, err)
+	// Documents are counted once however many of the words they have, like
+	// Search counts them
+	assert.NoError(t, r.NumberOfResults([]string{"exampl", "content", "save"}, store))
+	assert.Equal(t, int64(2), r.Count)
+
+	results, err := Search("example content save", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, r.Count, results.Count)
 }
 
-func TestSearch_SearchWithNoIndex(t *testing.T) {
-	defer tearDbDown(session)
+func TestSearch_SearchMissingDocument(t *testing.T) {
+	defer tearDbDown(store)
+
+	putDocuments(t, []*Document{{ID: "1", Content: "Hank sells propane"}})
 
-	rdb.DB(Conf.Database.Name).Table(Conf.Tables.IndexTable).IndexDrop("word").Exec(session)
+	// Indexes left behind by a document that's gone aren't found
+	assert.NoError(t, IndexBatchPut(store, Indexer("Hank drinks beer", "2")))
 
-	_, err := Search("hello", session, 1)
-	assert.Error(t, err)
+	results, err := Search("hank", store, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), results.Count)
+	assert.Len(t, results.Results, 1)
+
+	r := new(Results)
+	assert.NoError(t, r.NumberOfResults([]string{"hank"}, store))
+	assert.Equal(t, int64(1), r.Count)
 }
 
 func TestSearch_parsePageNumber(t *testing.T) {
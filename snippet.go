@@ -0,0 +1,238 @@
+package wally
+
+import (
+	"bytes"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// ellipsis marks where a snippet has been cut from the rest of the text.
+const ellipsis = "..."
+
+// DefaultSnippetLength is the most bytes a snippet holds when Highlight doesn't
+// give a length.
+const DefaultSnippetLength = 150
+
+// snippetLength returns the configured snippet length.
+func snippetLength() int {
+	if n := config().Highlight.Length; n > 0 {
+		return n
+	}
+	return DefaultSnippetLength
+}
+
+// Snippet returns the part of text that best matches terms, with each word
+// analysed as one of terms wrapped in the configured Highlight tags. The
+// snippet is the stretch of text no longer than Highlight.Length bytes with the
+// most distinct terms, and then the most matches, starting with a few words of
+// context. It's cut on word boundaries using TruncateText and an ellipsis
+// marks where text has been left out. When none of terms are in text the start
+// of the text is returned. Words are analysed using language, and the text is
+// escaped for HTML when Highlight.EscapeHTML is set.
+func Snippet(text, language string, terms []string) string {
+	h := config().Highlight
+	length := snippetLength()
+
+	escape := func(s string) string { return s }
+	if h.EscapeHTML {
+		escape = html.EscapeString
+	}
+
+	spans := tokenSpans(text)
+	var matches []string
+	if isNGramField(ContentField) {
+		matches = matchGrams(spans, terms)
+	} else {
+		matches = matchSpans(spans, documentAnalyzer(language), terms)
+	}
+
+	first, last := bestWindow(spans, matches, length)
+	if first < 0 {
+		return escape(TruncateText(text, " "+ellipsis, length))
+	}
+
+	// Lead in with the words before the first match, taking up to half of
+	// the room left so the matches sit in the middle of the snippet, or more
+	// when there isn't much text after them.
+	room := length - (spans[last].End - spans[first].Start)
+	lead := room / 2
+	if after := len(text) - spans[last].End; after < room-lead {
+		lead = room - after
+	}
+
+	k := first
+	for k > 0 && spans[first].Start-spans[k-1].Start <= lead {
+		k--
+	}
+
+	from := 0
+	if k > 0 {
+		from = spans[k].Start
+	}
+	cut := TruncateText(text[from:], "", length)
+	to := from + len(cut)
+
+	var b bytes.Buffer
+	if from > 0 {
+		b.WriteString(ellipsis + " ")
+	}
+
+	// Matched words written without anything between them, like Chinese
+	// characters, share a pair of tags.
+	offset, open := from, false
+	for i := k; i < len(spans) && spans[i].End <= to; i++ {
+		if matches[i] == "" {
+			continue
+		}
+		if !open || spans[i].Start != offset {
+			if open {
+				b.WriteString(h.PostTag)
+			}
+			b.WriteString(escape(text[offset:spans[i].Start]))
+			b.WriteString(h.PreTag)
+		}
+		b.WriteString(escape(text[spans[i].Start:spans[i].End]))
+		offset, open = spans[i].End, true
+	}
+	if open {
+		b.WriteString(h.PostTag)
+	}
+	b.WriteString(escape(text[offset:to]))
+
+	if strings.IndexFunc(text[to:], isSnippetRune) >= 0 {
+		b.WriteString(" " + ellipsis)
+	}
+	return b.String()
+}
+
+// matchSpans analyses each word of text, returning the term of each word that's
+// one of terms and an empty string for those that aren't.
+func matchSpans(spans []tokenSpan, analyzer *Analyzer, terms []string) []string {
+	wanted := map[string]bool{}
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	matches := make([]string, len(spans))
+	normalised := map[string]string{}
+	for i, span := range spans {
+		term, ok := normalised[span.Word]
+		if !ok {
+			term = analyzer.Normalise(span.Word)
+			normalised[span.Word] = term
+		}
+		if wanted[term] {
+			matches[i] = term
+		}
+	}
+	return matches
+}
+
+// matchGrams finds terms of a field indexed as n-grams in text, they match
+// anywhere in the runs of words ngramRuns cuts text into, so each word that's
+// part of a match is given the term it's part of.
+func matchGrams(spans []tokenSpan, terms []string) []string {
+	matches := make([]string, len(spans))
+
+	for first := 0; first < len(spans); {
+		last := first
+		for last+1 < len(spans) && spans[last+1].Start == spans[last].End {
+			last++
+		}
+
+		// Each byte of the folded run is owned by the word it came from
+		var run bytes.Buffer
+		owners := []int{}
+		for i := first; i <= last; i++ {
+			word := foldGrams(spans[i].Word)
+			run.WriteString(word)
+			for j := 0; j < len(word); j++ {
+				owners = append(owners, i)
+			}
+		}
+
+		folded := run.String()
+		for _, term := range terms {
+			if term == "" {
+				continue
+			}
+			for at := 0; at < len(folded); {
+				i := strings.Index(folded[at:], term)
+				if i < 0 {
+					break
+				}
+				for _, owner := range owners[at+i : at+i+len(term)] {
+					if matches[owner] == "" {
+						matches[owner] = term
+					}
+				}
+				at += i + 1
+			}
+		}
+
+		first = last + 1
+	}
+	return matches
+}
+
+// bestWindow finds the run of words no more than length bytes long with the
+// most distinct matches, ties going to the one with the most matches and then
+// the earliest. The first and last matching word of the run are returned, or
+// -1 when nothing matches.
+func bestWindow(spans []tokenSpan, matches []string, length int) (int, int) {
+	first, last := -1, -1
+	bestDistinct, bestCount := 0, 0
+
+	for i := range spans {
+		if matches[i] == "" {
+			continue
+		}
+
+		distinct := map[string]bool{}
+		count, end := 0, i
+		for j := i; j < len(spans) && spans[j].End-spans[i].Start <= length; j++ {
+			if matches[j] != "" {
+				distinct[matches[j]] = true
+				count++
+				end = j
+			}
+		}
+
+		if len(distinct) > bestDistinct || (len(distinct) == bestDistinct && count > bestCount) {
+			first, last = i, end
+			bestDistinct, bestCount = len(distinct), count
+		}
+	}
+	return first, last
+}
+
+// isSnippetRune reports whether r is worth showing, text after a snippet that's
+// only whitespace and punctuation doesn't need an ellipsis.
+func isSnippetRune(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsPunct(r)
+}
+
+// highlightTerms returns the terms of a query that are searched for in a
+// document's content, terms excluded by NOT or - aren't highlighted. When the
+// content is indexed as n-grams a phrase of n-grams is highlighted as the run
+// of text it was cut from.
+func highlightTerms(root node) []string {
+	var all, results []matcher
+	queryMatchers(root, true, &all, &results)
+
+	terms := []string{}
+	for _, m := range results {
+		keys := m.keys()
+		if p, ok := m.(*phrase); ok && p.Field == ContentField && isNGramField(ContentField) {
+			keys = []string{p.Text}
+		}
+		for _, key := range keys {
+			// Terms from other fields are prefixed with the field's name
+			if !strings.Contains(key, ":") && !containsString(terms, key) {
+				terms = append(terms, key)
+			}
+		}
+	}
+	return terms
+}
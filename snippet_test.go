@@ -0,0 +1,74 @@
+package wally
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnippet_Snippet(t *testing.T) {
+	long := strings.Repeat("Dale smokes in the alley. ", 10)
+
+	tests := []struct {
+		Text    string
+		Terms   []string
+		Snippet string
+	}{
+		{"Hank sells propane", []string{"propan"}, "Hank sells <b>propane</b>"},
+		{"Propane, and propane accessories.", []string{"propan"}, "<b>Propane</b>, and <b>propane</b> accessories."},
+		{"Hank sells propane", []string{"beer"}, "Hank sells propane"},
+		{long, []string{"beer"}, "Dale smokes in the alley. Dale smokes in the alley. Dale smokes in the alley. Dale smokes in the alley. Dale smokes in the alley. Dale smokes in the ..."},
+		{
+			long + "Hank sells propane and propane accessories in Arlen. " + long,
+			[]string{"propan", "arlen"},
+			"... in the alley. Dale smokes in the alley. Hank sells <b>propane</b> and <b>propane</b> accessories in <b>Arlen</b>. Dale smokes in the alley. Dale smokes in the alley. Dale ...",
+		},
+		{
+			"Propane " + long + "Propane in Arlen",
+			[]string{"propan", "arlen"},
+			"... Dale smokes in the alley. Dale smokes in the alley. Dale smokes in the alley. Dale smokes in the alley. Dale smokes in the alley. <b>Propane</b> in <b>Arlen</b>",
+		},
+	}
+
+	for _, test := range tests {
+		snippet := Snippet(test.Text, "", test.Terms)
+		assert.Equal(t, test.Snippet, snippet, test.Text)
+	}
+}
+
+func TestSnippet_SnippetTags(t *testing.T) {
+	Conf.Highlight.PreTag, Conf.Highlight.PostTag = "\x1b[1m", "\x1b[0m"
+	Conf.Highlight.Length = 20
+	defer func() { Conf.Highlight = DefaultConfig().Highlight }()
+
+	assert.Equal(t, "... Hank \x1b[1msells\x1b[0m propane ...", Snippet("Dale smokes in the alley, Hank sells propane and propane accessories", "", []string{"sell"}))
+}
+
+func TestSnippet_SnippetEscape(t *testing.T) {
+	text := `<script>alert(1)</script> Hank sells "propane" & propane accessories`
+
+	assert.Equal(t,
+		"&lt;script&gt;alert(1)&lt;/script&gt; Hank sells &#34;<b>propane</b>&#34; &amp; <b>propane</b> accessories",
+		Snippet(text, "", []string{"propan"}))
+	assert.Equal(t, "&lt;b&gt;Hank&lt;/b&gt;", Snippet("<b>Hank</b>", "", []string{"beer"}))
+
+	Conf.Highlight.EscapeHTML = false
+	defer func() { Conf.Highlight = DefaultConfig().Highlight }()
+
+	assert.Equal(t, "<b>Hank</b>", Snippet("<b>Hank</b>", "", []string{"beer"}))
+}
+
+func TestSnippet_SnippetNoLength(t *testing.T) {
+	Conf.Highlight.Length = 0
+	defer func() { Conf.Highlight = DefaultConfig().Highlight }()
+
+	assert.Equal(t, "Hank sells <b>propane</b>", Snippet("Hank sells propane", "", []string{"propan"}))
+	assert.Equal(t, "Hank sells propane", Snippet("Hank sells propane", "", []string{"beer"}))
+}
+
+func TestSnippet_highlightTerms(t *testing.T) {
+	root, err := parseQuery(`"king hill" OR title:propane -beer author:hank hank`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"king", "hill", "hank"}, highlightTerms(root))
+}
@@ -0,0 +1,444 @@
+package wally
+
+import "unicode/utf8"
+
+// Stem reduces an English word to its stem using the Porter2 (Snowball English)
+// algorithm, so that "examples", "example" and "exampled" are all indexed as
+// "exampl". The word should already be lowercase.
+//
+// The algorithm is described at http://snowball.tartarus.org/algorithms/english/stemmer.html
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+
+	if stem, ok := stemExceptions[word]; ok {
+		return stem
+	}
+
+	w := []rune(word)
+	if w[0] == '\'' {
+		w = w[1:]
+	}
+
+	// A y at the start of the word or after a vowel is a consonant.
+	for i := range w {
+		if w[i] == 'y' && (i == 0 || isVowel(w[i-1])) {
+			w[i] = 'Y'
+		}
+	}
+
+	r1, r2 := stemRegions(w)
+
+	w = stemStep0(w)
+	w = stemStep1a(w)
+
+	if stemInvariants[string(w)] {
+		return string(w)
+	}
+
+	w = stemStep1b(w, r1)
+	w = stemStep1c(w)
+	w = stemStep2(w, r1)
+	w = stemStep3(w, r1, r2)
+	w = stemStep4(w, r2)
+	w = stemStep5(w, r1, r2)
+
+	for i := range w {
+		if w[i] == 'Y' {
+			w[i] = 'y'
+		}
+	}
+	return string(w)
+}
+
+// stemExceptions are words that are stemmed irregularly or not at all.
+var stemExceptions = map[string]string{
+	"skis":   "ski",
+	"skies":  "sky",
+	"dying":  "die",
+	"lying":  "lie",
+	"tying":  "tie",
+	"idly":   "idl",
+	"gently": "gentl",
+	"ugly":   "ugli",
+	"early":  "earli",
+	"only":   "onli",
+	"singly": "singl",
+	"sky":    "sky",
+	"news":   "news",
+	"howe":   "howe",
+	"atlas":  "atlas",
+	"cosmos": "cosmos",
+	"bias":   "bias",
+	"andes":  "andes",
+}
+
+// stemInvariants are left alone once step 1a has removed any plural.
+var stemInvariants = map[string]bool{
+	"inning":  true,
+	"outing":  true,
+	"canning": true,
+	"herring": true,
+	"earring": true,
+	"proceed": true,
+	"exceed":  true,
+	"succeed": true,
+}
+
+type stemRule struct {
+	suffix      string
+	replacement string
+}
+
+func isVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+	return false
+}
+
+func hasVowel(w []rune) bool {
+	for _, r := range w {
+		if isVowel(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func runesHaveSuffix(w []rune, suffix string) bool {
+	s := []rune(suffix)
+	if len(s) > len(w) {
+		return false
+	}
+	for i := range s {
+		if w[len(w)-len(s)+i] != s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func runesHavePrefix(w []rune, prefix string) bool {
+	p := []rune(prefix)
+	if len(p) > len(w) {
+		return false
+	}
+	for i := range p {
+		if w[i] != p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// longestSuffix returns the first rule whose suffix ends w, rules must be
+// ordered longest suffix first.
+func longestSuffix(w []rune, rules []stemRule) (stemRule, bool) {
+	for _, rule := range rules {
+		if runesHaveSuffix(w, rule.suffix) {
+			return rule, true
+		}
+	}
+	return stemRule{}, false
+}
+
+func replaceSuffix(w []rune, suffix, replacement string) []rune {
+	w = w[:len(w)-len([]rune(suffix))]
+	return append(w, []rune(replacement)...)
+}
+
+// stemRegions returns the start of R1 and R2. R1 is the region after the first
+// non-vowel following a vowel, R2 is the same region found within R1.
+func stemRegions(w []rune) (int, int) {
+	r1 := -1
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if runesHavePrefix(w, prefix) {
+			r1 = len([]rune(prefix))
+			break
+		}
+	}
+	if r1 < 0 {
+		r1 = stemRegion(w, 0, isVowel)
+	}
+	return r1, stemRegion(w, r1, isVowel)
+}
+
+// stemRegion returns the start of the region after the first non-vowel
+// following a vowel, looking no earlier than start.
+func stemRegion(w []rune, start int, vowel func(rune) bool) int {
+	for i := start + 1; i < len(w); i++ {
+		if !vowel(w[i]) && vowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+// longestOf returns the longest of the suffixes that ends w, or an empty string
+// when none of them do.
+func longestOf(w []rune, suffixes ...string) string {
+	longest, n := "", 0
+	for _, suffix := range suffixes {
+		if l := utf8.RuneCountInString(suffix); l > n && runesHaveSuffix(w, suffix) {
+			longest, n = suffix, l
+		}
+	}
+	return longest
+}
+
+// suffixStart returns the position in w at which suffix begins.
+func suffixStart(w []rune, suffix string) int {
+	return len(w) - len([]rune(suffix))
+}
+
+// endsShortSyllable reports whether w ends with a vowel followed by a non-vowel
+// other than w, x or Y and preceded by a non-vowel, or is a vowel at the start
+// of the word followed by a non-vowel.
+func endsShortSyllable(w []rune) bool {
+	n := len(w)
+	if n == 2 {
+		return isVowel(w[0]) && !isVowel(w[1])
+	}
+	if n >= 3 {
+		last := w[n-1]
+		return !isVowel(w[n-3]) && isVowel(w[n-2]) && !isVowel(last) &&
+			last != 'w' && last != 'x' && last != 'Y'
+	}
+	return false
+}
+
+func isShortWord(w []rune, r1 int) bool {
+	return r1 >= len(w) && endsShortSyllable(w)
+}
+
+func stemStep0(w []rune) []rune {
+	for _, suffix := range []string{"'s'", "'s", "'"} {
+		if runesHaveSuffix(w, suffix) {
+			return w[:len(w)-len(suffix)]
+		}
+	}
+	return w
+}
+
+func stemStep1a(w []rune) []rune {
+	switch {
+	case runesHaveSuffix(w, "sses"):
+		return w[:len(w)-2]
+	case runesHaveSuffix(w, "ied"), runesHaveSuffix(w, "ies"):
+		if len(w) > 4 {
+			return w[:len(w)-2]
+		}
+		return w[:len(w)-1]
+	case runesHaveSuffix(w, "us"), runesHaveSuffix(w, "ss"):
+		return w
+	case runesHaveSuffix(w, "s"):
+		// Only when there is a vowel before the letter preceding the s, so
+		// "gaps" becomes "gap" but "gas" is left alone.
+		if len(w) > 2 && hasVowel(w[:len(w)-2]) {
+			return w[:len(w)-1]
+		}
+	}
+	return w
+}
+
+var stemStep1bRules = []stemRule{
+	{"eedly", "ee"},
+	{"ingly", ""},
+	{"edly", ""},
+	{"eed", "ee"},
+	{"ing", ""},
+	{"ed", ""},
+}
+
+func stemStep1b(w []rune, r1 int) []rune {
+	rule, ok := longestSuffix(w, stemStep1bRules)
+	if !ok {
+		return w
+	}
+
+	start := len(w) - len(rule.suffix)
+	if rule.replacement == "ee" {
+		if start >= r1 {
+			return replaceSuffix(w, rule.suffix, rule.replacement)
+		}
+		return w
+	}
+
+	if !hasVowel(w[:start]) {
+		return w
+	}
+	w = w[:start]
+
+	switch {
+	case runesHaveSuffix(w, "at"), runesHaveSuffix(w, "bl"), runesHaveSuffix(w, "iz"):
+		return append(w, 'e')
+	case endsDouble(w):
+		return w[:len(w)-1]
+	case isShortWord(w, r1):
+		return append(w, 'e')
+	}
+	return w
+}
+
+func endsDouble(w []rune) bool {
+	n := len(w)
+	if n < 2 || w[n-1] != w[n-2] {
+		return false
+	}
+	switch w[n-1] {
+	case 'b', 'd', 'f', 'g', 'm', 'n', 'p', 'r', 't':
+		return true
+	}
+	return false
+}
+
+func stemStep1c(w []rune) []rune {
+	n := len(w)
+	if n > 2 && (w[n-1] == 'y' || w[n-1] == 'Y') && !isVowel(w[n-2]) {
+		w[n-1] = 'i'
+	}
+	return w
+}
+
+var stemStep2Rules = []stemRule{
+	{"ization", "ize"},
+	{"ational", "ate"},
+	{"fulness", "ful"},
+	{"ousness", "ous"},
+	{"iveness", "ive"},
+	{"tional", "tion"},
+	{"biliti", "ble"},
+	{"lessli", "less"},
+	{"entli", "ent"},
+	{"ation", "ate"},
+	{"alism", "al"},
+	{"aliti", "al"},
+	{"ousli", "ous"},
+	{"iviti", "ive"},
+	{"fulli", "ful"},
+	{"enci", "ence"},
+	{"anci", "ance"},
+	{"abli", "able"},
+	{"izer", "ize"},
+	{"ator", "ate"},
+	{"alli", "al"},
+	{"bli", "ble"},
+	{"ogi", "og"},
+	{"li", ""},
+}
+
+func stemStep2(w []rune, r1 int) []rune {
+	rule, ok := longestSuffix(w, stemStep2Rules)
+	if !ok {
+		return w
+	}
+
+	start := len(w) - len(rule.suffix)
+	if start < r1 {
+		return w
+	}
+
+	switch rule.suffix {
+	case "ogi":
+		if start < 1 || w[start-1] != 'l' {
+			return w
+		}
+	case "li":
+		if start < 1 || !isValidLiEnding(w[start-1]) {
+			return w
+		}
+	}
+	return replaceSuffix(w, rule.suffix, rule.replacement)
+}
+
+func isValidLiEnding(r rune) bool {
+	switch r {
+	case 'c', 'd', 'e', 'g', 'h', 'k', 'm', 'n', 'r', 't':
+		return true
+	}
+	return false
+}
+
+var stemStep3Rules = []stemRule{
+	{"ational", "ate"},
+	{"tional", "tion"},
+	{"alize", "al"},
+	{"icate", "ic"},
+	{"iciti", "ic"},
+	{"ative", ""},
+	{"ical", "ic"},
+	{"ness", ""},
+	{"ful", ""},
+}
+
+func stemStep3(w []rune, r1, r2 int) []rune {
+	rule, ok := longestSuffix(w, stemStep3Rules)
+	if !ok {
+		return w
+	}
+
+	start := len(w) - len(rule.suffix)
+	if start < r1 || (rule.suffix == "ative" && start < r2) {
+		return w
+	}
+	return replaceSuffix(w, rule.suffix, rule.replacement)
+}
+
+var stemStep4Rules = []stemRule{
+	{"ement", ""},
+	{"ance", ""},
+	{"ence", ""},
+	{"able", ""},
+	{"ible", ""},
+	{"ment", ""},
+	{"ant", ""},
+	{"ent", ""},
+	{"ism", ""},
+	{"ate", ""},
+	{"iti", ""},
+	{"ous", ""},
+	{"ive", ""},
+	{"ize", ""},
+	{"ion", ""},
+	{"al", ""},
+	{"er", ""},
+	{"ic", ""},
+}
+
+func stemStep4(w []rune, r2 int) []rune {
+	rule, ok := longestSuffix(w, stemStep4Rules)
+	if !ok {
+		return w
+	}
+
+	start := len(w) - len(rule.suffix)
+	if start < r2 {
+		return w
+	}
+	if rule.suffix == "ion" && (start < 1 || (w[start-1] != 's' && w[start-1] != 't')) {
+		return w
+	}
+	return w[:start]
+}
+
+func stemStep5(w []rune, r1, r2 int) []rune {
+	n := len(w)
+	if n == 0 {
+		return w
+	}
+
+	switch w[n-1] {
+	case 'e':
+		if n-1 >= r2 || (n-1 >= r1 && !endsShortSyllable(w[:n-1])) {
+			return w[:n-1]
+		}
+	case 'l':
+		if n-1 >= r2 && n > 1 && w[n-2] == 'l' {
+			return w[:n-1]
+		}
+	}
+	return w
+}
@@ -0,0 +1,338 @@
+package wally
+
+// StemFrench reduces a lowercase French word to its stem using the Snowball
+// French algorithm.
+//
+// The algorithm is described at http://snowball.tartarus.org/algorithms/french/stemmer.html
+func StemFrench(word string) string {
+	w := []rune(word)
+
+	// A u or i between vowels, a y next to a vowel and a u after q are all
+	// consonants.
+	for i, r := range w {
+		prev := i > 0 && isFrenchVowel(w[i-1])
+		next := i < len(w)-1 && isFrenchVowel(w[i+1])
+		switch {
+		case (r == 'u' || r == 'i') && prev && next:
+			w[i] = r - 'a' + 'A'
+		case r == 'y' && (prev || next):
+			w[i] = 'Y'
+		case r == 'u' && i > 0 && w[i-1] == 'q':
+			w[i] = 'U'
+		}
+	}
+
+	rv := frenchRV(w)
+	r1 := stemRegion(w, 0, isFrenchVowel)
+	r2 := stemRegion(w, r1, isFrenchVowel)
+
+	w, ok := frenchStep1(w, rv, r1, r2)
+	if !ok {
+		w, ok = frenchStep2a(w, rv)
+	}
+	if !ok {
+		w, ok = frenchStep2b(w, rv, r2)
+	}
+
+	if ok {
+		// Step 3
+		switch n := len(w) - 1; {
+		case w[n] == 'Y':
+			w[n] = 'i'
+		case w[n] == 'ç':
+			w[n] = 'c'
+		}
+	} else {
+		w = frenchStep4(w, rv, r2)
+	}
+
+	w = frenchStep5(w)
+	w = frenchStep6(w)
+
+	for i, r := range w {
+		switch r {
+		case 'I':
+			w[i] = 'i'
+		case 'U':
+			w[i] = 'u'
+		case 'Y':
+			w[i] = 'y'
+		}
+	}
+	return string(w)
+}
+
+func isFrenchVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'y', 'â', 'à', 'ë', 'é', 'ê', 'è', 'ï', 'î', 'ô', 'û', 'ù':
+		return true
+	}
+	return false
+}
+
+// frenchRV returns the start of RV. If the word begins with two vowels RV is
+// the region after the third letter, otherwise it is the region after the
+// first vowel that doesn't begin the word.
+func frenchRV(w []rune) int {
+	for _, prefix := range []string{"par", "col", "tap"} {
+		if runesHavePrefix(w, prefix) {
+			return 3
+		}
+	}
+
+	if len(w) >= 2 && isFrenchVowel(w[0]) && isFrenchVowel(w[1]) {
+		if len(w) >= 3 {
+			return 3
+		}
+		return len(w)
+	}
+
+	for i := 1; i < len(w); i++ {
+		if isFrenchVowel(w[i]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+// frenchStep1 removes standard suffixes and reports whether it succeeded. The
+// adverb endings amment, emment and ment are changed but still count as a
+// failure so that the verb suffixes are tried next.
+func frenchStep1(w []rune, rv, r1, r2 int) ([]rune, bool) {
+	suffix := longestOf(w,
+		"ance", "iqUe", "isme", "able", "iste", "eux", "ances", "iqUes", "ismes", "ables", "istes",
+		"atrice", "ateur", "ation", "atrices", "ateurs", "ations", "logie", "logies",
+		"usion", "ution", "usions", "utions", "ence", "ences", "ement", "ements", "ité", "ités",
+		"if", "ive", "ifs", "ives", "eaux", "aux", "euse", "euses", "issement", "issements",
+		"amment", "emment", "ment", "ments")
+	if suffix == "" {
+		return w, false
+	}
+	start := suffixStart(w, suffix)
+
+	switch suffix {
+	case "ance", "iqUe", "isme", "able", "iste", "eux", "ances", "iqUes", "ismes", "ables", "istes":
+		if start < r2 {
+			return w, false
+		}
+		w = w[:start]
+	case "atrice", "ateur", "ation", "atrices", "ateurs", "ations":
+		if start < r2 {
+			return w, false
+		}
+		w = w[:start]
+		if runesHaveSuffix(w, "ic") {
+			if suffixStart(w, "ic") >= r2 {
+				w = w[:len(w)-2]
+			} else {
+				w = replaceSuffix(w, "ic", "iqU")
+			}
+		}
+	case "logie", "logies":
+		if start < r2 {
+			return w, false
+		}
+		w = replaceSuffix(w, suffix, "log")
+	case "usion", "ution", "usions", "utions":
+		if start < r2 {
+			return w, false
+		}
+		w = replaceSuffix(w, suffix, "u")
+	case "ence", "ences":
+		if start < r2 {
+			return w, false
+		}
+		w = replaceSuffix(w, suffix, "ent")
+	case "ement", "ements":
+		if start < rv {
+			return w, false
+		}
+		w = w[:start]
+		switch {
+		case runesHaveSuffix(w, "iv"):
+			if suffixStart(w, "iv") >= r2 {
+				w = w[:len(w)-2]
+				w = deleteIfIn(w, r2, "at")
+			}
+		case runesHaveSuffix(w, "eus"):
+			if suffixStart(w, "eus") >= r2 {
+				w = w[:len(w)-3]
+			} else if suffixStart(w, "eus") >= r1 {
+				w = replaceSuffix(w, "eus", "eux")
+			}
+		case runesHaveSuffix(w, "abl"), runesHaveSuffix(w, "iqU"):
+			w = deleteIfIn(w, r2, "abl", "iqU")
+		case runesHaveSuffix(w, "ièr"), runesHaveSuffix(w, "Ièr"):
+			if s := longestOf(w, "ièr", "Ièr"); suffixStart(w, s) >= rv {
+				w = replaceSuffix(w, s, "i")
+			}
+		}
+	case "ité", "ités":
+		if start < r2 {
+			return w, false
+		}
+		w = w[:start]
+		switch {
+		case runesHaveSuffix(w, "abil"):
+			if suffixStart(w, "abil") >= r2 {
+				w = w[:len(w)-4]
+			} else {
+				w = replaceSuffix(w, "abil", "abl")
+			}
+		case runesHaveSuffix(w, "ic"):
+			if suffixStart(w, "ic") >= r2 {
+				w = w[:len(w)-2]
+			} else {
+				w = replaceSuffix(w, "ic", "iqU")
+			}
+		case runesHaveSuffix(w, "iv"):
+			w = deleteIfIn(w, r2, "iv")
+		}
+	case "if", "ive", "ifs", "ives":
+		if start < r2 {
+			return w, false
+		}
+		w = w[:start]
+		if runesHaveSuffix(w, "at") && suffixStart(w, "at") >= r2 {
+			w = w[:len(w)-2]
+			if runesHaveSuffix(w, "ic") {
+				if suffixStart(w, "ic") >= r2 {
+					w = w[:len(w)-2]
+				} else {
+					w = replaceSuffix(w, "ic", "iqU")
+				}
+			}
+		}
+	case "eaux":
+		w = replaceSuffix(w, suffix, "eau")
+	case "aux":
+		if start < r1 {
+			return w, false
+		}
+		w = replaceSuffix(w, suffix, "al")
+	case "euse", "euses":
+		if start >= r2 {
+			w = w[:start]
+		} else if start >= r1 {
+			w = replaceSuffix(w, suffix, "eux")
+		} else {
+			return w, false
+		}
+	case "issement", "issements":
+		if start < r1 || start == 0 || isFrenchVowel(w[start-1]) {
+			return w, false
+		}
+		w = w[:start]
+	case "amment":
+		if start < rv {
+			return w, false
+		}
+		return replaceSuffix(w, suffix, "ant"), false
+	case "emment":
+		if start < rv {
+			return w, false
+		}
+		return replaceSuffix(w, suffix, "ent"), false
+	case "ment", "ments":
+		if start-1 < rv || start == 0 || !isFrenchVowel(w[start-1]) {
+			return w, false
+		}
+		return w[:start], false
+	}
+	return w, true
+}
+
+var frenchIVerbSuffixes = []string{
+	"îmes", "ît", "îtes", "i", "ie", "ies", "ir", "ira", "irai", "iraIent", "irais", "irait", "iras",
+	"irent", "irez", "iriez", "irions", "irons", "iront", "is", "issaIent", "issais", "issait",
+	"issant", "issante", "issantes", "issants", "isse", "issent", "isses", "issez", "issiez",
+	"issions", "issons", "it",
+}
+
+func frenchStep2a(w []rune, rv int) ([]rune, bool) {
+	suffix := longestOf(w, frenchIVerbSuffixes...)
+	start := suffixStart(w, suffix)
+	if suffix == "" || start < rv+1 || isFrenchVowel(w[start-1]) {
+		return w, false
+	}
+	return w[:start], true
+}
+
+func frenchStep2b(w []rune, rv, r2 int) ([]rune, bool) {
+	suffix := longestOf(w,
+		"ions", "é", "ée", "ées", "és", "èrent", "er", "era", "erai", "eraIent", "erais", "erait",
+		"eras", "erez", "eriez", "erions", "erons", "eront", "ez", "iez",
+		"âmes", "ât", "âtes", "a", "ai", "aIent", "ais", "ait", "ant", "ante", "antes", "ants", "as",
+		"asse", "assent", "asses", "assiez", "assions")
+	start := suffixStart(w, suffix)
+	if suffix == "" || start < rv {
+		return w, false
+	}
+
+	switch suffix {
+	case "ions":
+		if start < r2 {
+			return w, false
+		}
+		w = w[:start]
+	case "âmes", "ât", "âtes", "a", "ai", "aIent", "ais", "ait", "ant", "ante", "antes", "ants", "as",
+		"asse", "assent", "asses", "assiez", "assions":
+		w = w[:start]
+		if runesHaveSuffix(w, "e") && len(w)-1 >= rv {
+			w = w[:len(w)-1]
+		}
+	default:
+		w = w[:start]
+	}
+	return w, true
+}
+
+func frenchStep4(w []rune, rv, r2 int) []rune {
+	if n := len(w); n > 1 && w[n-1] == 's' {
+		switch w[n-2] {
+		case 'a', 'i', 'o', 'u', 'è', 's':
+		default:
+			w = w[:n-1]
+		}
+	}
+
+	suffix := longestOf(w, "ion", "ier", "ière", "Ier", "Ière", "e", "ë")
+	start := suffixStart(w, suffix)
+	if suffix == "" || start < rv {
+		return w
+	}
+
+	switch suffix {
+	case "ion":
+		if start >= r2 && start-1 >= rv && (w[start-1] == 's' || w[start-1] == 't') {
+			w = w[:start]
+		}
+	case "ier", "ière", "Ier", "Ière":
+		w = replaceSuffix(w, suffix, "i")
+	case "e":
+		w = w[:start]
+	case "ë":
+		if runesHaveSuffix(w, "guë") && start-2 >= rv {
+			w = w[:start]
+		}
+	}
+	return w
+}
+
+func frenchStep5(w []rune) []rune {
+	if longestOf(w, "enn", "onn", "ett", "ell", "eill") != "" {
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+func frenchStep6(w []rune) []rune {
+	i := len(w) - 1
+	for i >= 0 && !isFrenchVowel(w[i]) {
+		i--
+	}
+	if i >= 0 && i < len(w)-1 && (w[i] == 'é' || w[i] == 'è') {
+		w[i] = 'e'
+	}
+	return w
+}
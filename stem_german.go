@@ -0,0 +1,140 @@
+package wally
+
+import "strings"
+
+// StemGerman reduces a lowercase German word to its stem using the Snowball
+// German algorithm, umlauts are removed from the stem.
+//
+// The algorithm is described at http://snowball.tartarus.org/algorithms/german/stemmer.html
+func StemGerman(word string) string {
+	w := []rune(strings.Replace(word, "ß", "ss", -1))
+
+	// A u or y between vowels is a consonant.
+	for i := 1; i < len(w)-1; i++ {
+		if isGermanVowel(w[i-1]) && isGermanVowel(w[i+1]) {
+			switch w[i] {
+			case 'u':
+				w[i] = 'U'
+			case 'y':
+				w[i] = 'Y'
+			}
+		}
+	}
+
+	r1 := stemRegion(w, 0, isGermanVowel)
+	r2 := stemRegion(w, r1, isGermanVowel)
+
+	// The region before R1 must hold at least three letters.
+	if r1 < 3 {
+		r1 = 3
+	}
+
+	w = germanStep1(w, r1)
+	w = germanStep2(w, r1)
+	w = germanStep3(w, r1, r2)
+
+	for i, r := range w {
+		switch r {
+		case 'U', 'ü':
+			w[i] = 'u'
+		case 'Y':
+			w[i] = 'y'
+		case 'ä':
+			w[i] = 'a'
+		case 'ö':
+			w[i] = 'o'
+		}
+	}
+	return string(w)
+}
+
+func isGermanVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'y', 'ä', 'ö', 'ü':
+		return true
+	}
+	return false
+}
+
+func isGermanSEnding(r rune) bool {
+	switch r {
+	case 'b', 'd', 'f', 'g', 'h', 'k', 'l', 'm', 'n', 'r', 't':
+		return true
+	}
+	return false
+}
+
+func isGermanSTEnding(r rune) bool {
+	return r != 'r' && isGermanSEnding(r)
+}
+
+func germanStep1(w []rune, r1 int) []rune {
+	suffix := longestOf(w, "em", "ern", "er", "e", "en", "es", "s")
+	start := suffixStart(w, suffix)
+	if suffix == "" || start < r1 {
+		return w
+	}
+
+	switch suffix {
+	case "e", "en", "es":
+		w = w[:start]
+		if runesHaveSuffix(w, "niss") {
+			w = w[:len(w)-1]
+		}
+	case "s":
+		if start > 0 && isGermanSEnding(w[start-1]) {
+			w = w[:start]
+		}
+	default:
+		w = w[:start]
+	}
+	return w
+}
+
+func germanStep2(w []rune, r1 int) []rune {
+	suffix := longestOf(w, "en", "er", "est", "st")
+	start := suffixStart(w, suffix)
+	if suffix == "" || start < r1 {
+		return w
+	}
+
+	if suffix == "st" {
+		// The st-ending must itself be preceded by at least three letters.
+		if start >= 4 && isGermanSTEnding(w[start-1]) {
+			w = w[:start]
+		}
+		return w
+	}
+	return w[:start]
+}
+
+func germanStep3(w []rune, r1, r2 int) []rune {
+	suffix := longestOf(w, "end", "ung", "ig", "ik", "isch", "lich", "heit", "keit")
+	start := suffixStart(w, suffix)
+	if suffix == "" || start < r2 {
+		return w
+	}
+
+	switch suffix {
+	case "end", "ung":
+		w = w[:start]
+		if runesHaveSuffix(w, "ig") && len(w)-2 >= r2 && !runesHaveSuffix(w, "eig") {
+			w = w[:len(w)-2]
+		}
+	case "ig", "ik", "isch":
+		if start == 0 || w[start-1] != 'e' {
+			w = w[:start]
+		}
+	case "lich", "heit":
+		w = w[:start]
+		if (runesHaveSuffix(w, "er") || runesHaveSuffix(w, "en")) && len(w)-2 >= r1 {
+			w = w[:len(w)-2]
+		}
+	case "keit":
+		w = w[:start]
+		if s := longestOf(w, "lich", "ig"); s != "" && suffixStart(w, s) >= r2 {
+			w = w[:suffixStart(w, s)]
+		}
+	}
+	return w
+}
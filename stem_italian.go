@@ -0,0 +1,190 @@
+package wally
+
+// StemItalian reduces a lowercase Italian word to its stem using the Snowball
+// Italian algorithm.
+//
+// The algorithm is described at http://snowball.tartarus.org/algorithms/italian/stemmer.html
+func StemItalian(word string) string {
+	w := []rune(word)
+
+	for i, r := range w {
+		switch r {
+		case 'á':
+			w[i] = 'à'
+		case 'é':
+			w[i] = 'è'
+		case 'í':
+			w[i] = 'ì'
+		case 'ó':
+			w[i] = 'ò'
+		case 'ú':
+			w[i] = 'ù'
+		}
+	}
+
+	// A u after q, and a u or i between vowels, are consonants.
+	for i := range w {
+		switch {
+		case w[i] == 'u' && i > 0 && w[i-1] == 'q':
+			w[i] = 'U'
+		case (w[i] == 'u' || w[i] == 'i') && i > 0 && i < len(w)-1 && isItalianVowel(w[i-1]) && isItalianVowel(w[i+1]):
+			w[i] = w[i] - 'a' + 'A'
+		}
+	}
+
+	rv := romanceRV(w, isItalianVowel)
+	r1 := stemRegion(w, 0, isItalianVowel)
+	r2 := stemRegion(w, r1, isItalianVowel)
+
+	w = italianStep0(w, rv)
+
+	before := len(w)
+	w = italianStep1(w, rv, r1, r2)
+	if len(w) == before {
+		w = italianStep2(w, rv)
+	}
+	w = italianStep3(w, rv)
+
+	for i, r := range w {
+		switch r {
+		case 'I':
+			w[i] = 'i'
+		case 'U':
+			w[i] = 'u'
+		}
+	}
+	return string(w)
+}
+
+func isItalianVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'à', 'è', 'ì', 'ò', 'ù':
+		return true
+	}
+	return false
+}
+
+func italianStep0(w []rune, rv int) []rune {
+	suffix := longestOf(w,
+		"ci", "gli", "la", "le", "li", "lo", "mi", "ne", "si", "ti", "vi", "sene", "gliela", "gliele",
+		"glieli", "glielo", "gliene", "mela", "mele", "meli", "melo", "mene", "tela", "tele", "teli",
+		"telo", "tene", "cela", "cele", "celi", "celo", "cene", "vela", "vele", "veli", "velo", "vene")
+	start := suffixStart(w, suffix)
+	if suffix == "" || start < rv {
+		return w
+	}
+
+	stem := w[:start]
+	before := longestOf(stem, "ando", "endo", "ar", "er", "ir")
+	if before == "" || suffixStart(stem, before) < rv {
+		return w
+	}
+
+	if before == "ando" || before == "endo" {
+		return stem
+	}
+	return append(stem, 'e')
+}
+
+func italianStep1(w []rune, rv, r1, r2 int) []rune {
+	suffix := longestOf(w,
+		"anza", "anze", "ico", "ici", "ica", "ice", "iche", "ichi", "ismo", "ismi", "abile", "abili",
+		"ibile", "ibili", "ista", "iste", "isti", "istà", "istè", "istì", "oso", "osi", "osa", "ose",
+		"mente", "atrice", "atrici", "ante", "anti", "azione", "azioni", "atore", "atori",
+		"logia", "logie", "uzione", "uzioni", "usione", "usioni", "enza", "enze",
+		"amento", "amenti", "imento", "imenti", "amente", "ità", "ivo", "ivi", "iva", "ive")
+	if suffix == "" {
+		return w
+	}
+	start := suffixStart(w, suffix)
+
+	switch suffix {
+	case "azione", "azioni", "atore", "atori":
+		if start < r2 {
+			return w
+		}
+		w = deleteIfIn(w[:start], r2, "ic")
+	case "logia", "logie":
+		if start < r2 {
+			return w
+		}
+		w = replaceSuffix(w, suffix, "log")
+	case "uzione", "uzioni", "usione", "usioni":
+		if start < r2 {
+			return w
+		}
+		w = replaceSuffix(w, suffix, "u")
+	case "enza", "enze":
+		if start < r2 {
+			return w
+		}
+		w = replaceSuffix(w, suffix, "ente")
+	case "amento", "amenti", "imento", "imenti":
+		if start < rv {
+			return w
+		}
+		w = w[:start]
+	case "amente":
+		if start < r1 {
+			return w
+		}
+		w = w[:start]
+		if runesHaveSuffix(w, "iv") && suffixStart(w, "iv") >= r2 {
+			w = deleteIfIn(w[:len(w)-2], r2, "at")
+		} else {
+			w = deleteIfIn(w, r2, "os", "ic", "abil")
+		}
+	case "ità":
+		if start < r2 {
+			return w
+		}
+		w = deleteIfIn(w[:start], r2, "abil", "ic", "iv")
+	case "ivo", "ivi", "iva", "ive":
+		if start < r2 {
+			return w
+		}
+		w = w[:start]
+		if runesHaveSuffix(w, "at") && suffixStart(w, "at") >= r2 {
+			w = deleteIfIn(w[:len(w)-2], r2, "ic")
+		}
+	default:
+		if start < r2 {
+			return w
+		}
+		w = w[:start]
+	}
+	return w
+}
+
+var italianVerbSuffixes = []string{
+	"ammo", "ando", "ano", "are", "arono", "asse", "assero", "assi", "assimo", "ata", "ate", "ati",
+	"ato", "ava", "avamo", "avano", "avate", "avi", "avo", "emmo", "enda", "ende", "endi", "endo",
+	"erà", "erai", "eranno", "ere", "erebbe", "erebbero", "erei", "eremmo", "eremo", "ereste",
+	"eresti", "erete", "erò", "erono", "essero", "ete", "eva", "evamo", "evano", "evate", "evi",
+	"evo", "iamo", "immo", "irà", "irai", "iranno", "ire", "irebbe", "irebbero", "irei",
+	"iremmo", "iremo", "ireste", "iresti", "irete", "irò", "irono", "isca", "iscano", "isce",
+	"isci", "isco", "iscono", "issero", "ita", "ite", "iti", "ito", "iva", "ivamo", "ivano",
+	"ivate", "ivi", "ivo", "ar", "ir",
+}
+
+func italianStep2(w []rune, rv int) []rune {
+	suffix := longestOf(w, italianVerbSuffixes...)
+	if suffix == "" || suffixStart(w, suffix) < rv {
+		return w
+	}
+	return w[:suffixStart(w, suffix)]
+}
+
+func italianStep3(w []rune, rv int) []rune {
+	if suffix := longestOf(w, "a", "e", "i", "o", "à", "è", "ì", "ò"); suffix != "" && suffixStart(w, suffix) >= rv {
+		w = w[:len(w)-1]
+		if runesHaveSuffix(w, "i") && len(w)-1 >= rv {
+			w = w[:len(w)-1]
+		}
+	}
+
+	if suffix := longestOf(w, "ch", "gh"); suffix != "" && suffixStart(w, suffix) >= rv {
+		w = w[:len(w)-1]
+	}
+	return w
+}
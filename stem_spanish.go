@@ -0,0 +1,247 @@
+package wally
+
+// StemSpanish reduces a lowercase Spanish word to its stem using the Snowball
+// Spanish algorithm, acute accents are removed from the stem.
+//
+// The algorithm is described at http://snowball.tartarus.org/algorithms/spanish/stemmer.html
+func StemSpanish(word string) string {
+	w := []rune(word)
+
+	rv := romanceRV(w, isSpanishVowel)
+	r1 := stemRegion(w, 0, isSpanishVowel)
+	r2 := stemRegion(w, r1, isSpanishVowel)
+
+	w = spanishStep0(w, rv)
+
+	before := len(w)
+	w = spanishStep1(w, r1, r2)
+	if len(w) == before {
+		before = len(w)
+		w = spanishStep2a(w, rv)
+		if len(w) == before {
+			w = spanishStep2b(w, rv)
+		}
+	}
+	w = spanishStep3(w, rv)
+
+	for i, r := range w {
+		w[i] = unaccent(r)
+	}
+	return string(w)
+}
+
+func isSpanishVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'á', 'é', 'í', 'ó', 'ú', 'ü':
+		return true
+	}
+	return false
+}
+
+// unaccent removes an acute accent from a vowel.
+func unaccent(r rune) rune {
+	switch r {
+	case 'á':
+		return 'a'
+	case 'é':
+		return 'e'
+	case 'í':
+		return 'i'
+	case 'ó':
+		return 'o'
+	case 'ú':
+		return 'u'
+	}
+	return r
+}
+
+// romanceRV returns the start of RV as defined for the Spanish, Italian and
+// Portuguese stemmers. If the second letter is a consonant RV is the region
+// after the next vowel, if the first two letters are vowels it is the region
+// after the next consonant, otherwise it is the region after the third letter.
+func romanceRV(w []rune, vowel func(rune) bool) int {
+	if len(w) < 2 {
+		return len(w)
+	}
+
+	switch {
+	case !vowel(w[1]):
+		for i := 2; i < len(w); i++ {
+			if vowel(w[i]) {
+				return i + 1
+			}
+		}
+	case vowel(w[0]):
+		for i := 2; i < len(w); i++ {
+			if !vowel(w[i]) {
+				return i + 1
+			}
+		}
+	default:
+		if len(w) >= 3 {
+			return 3
+		}
+	}
+	return len(w)
+}
+
+func spanishStep0(w []rune, rv int) []rune {
+	suffix := longestOf(w, "me", "se", "sela", "selo", "selas", "selos", "la", "le", "lo", "las", "les", "los", "nos")
+	start := suffixStart(w, suffix)
+	if suffix == "" || start < rv {
+		return w
+	}
+
+	stem := w[:start]
+	switch before := longestOf(stem, "iéndo", "ándo", "ár", "ér", "ír", "ando", "iendo", "ar", "er", "ir", "yendo"); before {
+	case "":
+		return w
+	case "yendo":
+		b := suffixStart(stem, before)
+		if b < rv || b == 0 || stem[b-1] != 'u' {
+			return w
+		}
+	default:
+		if suffixStart(stem, before) < rv {
+			return w
+		}
+		for i := suffixStart(stem, before); i < len(stem); i++ {
+			stem[i] = unaccent(stem[i])
+		}
+	}
+	return stem
+}
+
+func spanishStep1(w []rune, r1, r2 int) []rune {
+	suffix := longestOf(w,
+		"anza", "anzas", "ico", "ica", "icos", "icas", "ismo", "ismos", "able", "ables", "ible", "ibles",
+		"ista", "istas", "oso", "osa", "osos", "osas", "amiento", "amientos", "imiento", "imientos",
+		"adora", "ador", "ación", "adoras", "adores", "aciones", "ante", "antes", "ancia", "ancias",
+		"logía", "logías", "ución", "uciones", "encia", "encias", "amente", "mente",
+		"idad", "idades", "iva", "ivo", "ivas", "ivos")
+	if suffix == "" {
+		return w
+	}
+	start := suffixStart(w, suffix)
+
+	switch suffix {
+	case "adora", "ador", "ación", "adoras", "adores", "aciones", "ante", "antes", "ancia", "ancias":
+		if start < r2 {
+			return w
+		}
+		w = w[:start]
+		w = deleteIfIn(w, r2, "ic")
+	case "logía", "logías":
+		if start < r2 {
+			return w
+		}
+		w = replaceSuffix(w, suffix, "log")
+	case "ución", "uciones":
+		if start < r2 {
+			return w
+		}
+		w = replaceSuffix(w, suffix, "u")
+	case "encia", "encias":
+		if start < r2 {
+			return w
+		}
+		w = replaceSuffix(w, suffix, "ente")
+	case "amente":
+		if start < r1 {
+			return w
+		}
+		w = w[:start]
+		if runesHaveSuffix(w, "iv") && suffixStart(w, "iv") >= r2 {
+			w = w[:len(w)-2]
+			w = deleteIfIn(w, r2, "at")
+		} else {
+			w = deleteIfIn(w, r2, "os", "ic", "ad")
+		}
+	case "mente":
+		if start < r2 {
+			return w
+		}
+		w = w[:start]
+		w = deleteIfIn(w, r2, "ante", "able", "ible")
+	case "idad", "idades":
+		if start < r2 {
+			return w
+		}
+		w = w[:start]
+		w = deleteIfIn(w, r2, "abil", "ic", "iv")
+	case "iva", "ivo", "ivas", "ivos":
+		if start < r2 {
+			return w
+		}
+		w = w[:start]
+		w = deleteIfIn(w, r2, "at")
+	default:
+		if start < r2 {
+			return w
+		}
+		w = w[:start]
+	}
+	return w
+}
+
+// deleteIfIn removes the longest of the suffixes ending w when it starts at or
+// after region.
+func deleteIfIn(w []rune, region int, suffixes ...string) []rune {
+	suffix := longestOf(w, suffixes...)
+	if suffix != "" && suffixStart(w, suffix) >= region {
+		return w[:suffixStart(w, suffix)]
+	}
+	return w
+}
+
+func spanishStep2a(w []rune, rv int) []rune {
+	suffix := longestOf(w, "ya", "ye", "yan", "yen", "yeron", "yendo", "yo", "yó", "yas", "yes", "yais", "yamos")
+	start := suffixStart(w, suffix)
+	if suffix == "" || start < rv || start == 0 || w[start-1] != 'u' {
+		return w
+	}
+	return w[:start]
+}
+
+var spanishVerbSuffixes = []string{
+	"arían", "arías", "arán", "arás", "aríais", "aría", "aréis", "aríamos", "aremos", "ará", "aré",
+	"erían", "erías", "erán", "erás", "eríais", "ería", "eréis", "eríamos", "eremos", "erá", "eré",
+	"irían", "irías", "irán", "irás", "iríais", "iría", "iréis", "iríamos", "iremos", "irá", "iré",
+	"aba", "ada", "ida", "ía", "ara", "iera", "ad", "ed", "id", "ase", "iese", "aste", "iste",
+	"an", "aban", "ían", "aran", "ieran", "asen", "iesen", "aron", "ieron", "ado", "ido", "ando",
+	"iendo", "ió", "ar", "er", "ir", "as", "abas", "adas", "idas", "ías", "aras", "ieras", "ases",
+	"ieses", "ís", "áis", "abais", "íais", "arais", "ierais", "aseis", "ieseis", "asteis",
+	"isteis", "ados", "idos", "amos", "ábamos", "íamos", "imos", "áramos", "iéramos", "iésemos",
+	"ásemos", "en", "es", "éis", "emos",
+}
+
+func spanishStep2b(w []rune, rv int) []rune {
+	suffix := longestOf(w, spanishVerbSuffixes...)
+	start := suffixStart(w, suffix)
+	if suffix == "" || start < rv {
+		return w
+	}
+
+	w = w[:start]
+	switch suffix {
+	case "en", "es", "éis", "emos":
+		if runesHaveSuffix(w, "gu") {
+			w = w[:len(w)-1]
+		}
+	}
+	return w
+}
+
+func spanishStep3(w []rune, rv int) []rune {
+	suffix := longestOf(w, "os", "a", "o", "á", "í", "ó", "e", "é")
+	start := suffixStart(w, suffix)
+	if suffix == "" || start < rv {
+		return w
+	}
+
+	w = w[:start]
+	if (suffix == "e" || suffix == "é") && runesHaveSuffix(w, "gu") && len(w)-1 >= rv {
+		w = w[:len(w)-1]
+	}
+	return w
+}
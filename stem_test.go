@@ -0,0 +1,162 @@
+package wally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStem_Stem(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output string
+	}{
+		{"a", "a"},
+		{"examples", "exampl"},
+		{"example", "exampl"},
+		{"caresses", "caress"},
+		{"flies", "fli"},
+		{"dies", "die"},
+		{"ties", "tie"},
+		{"cries", "cri"},
+		{"gas", "gas"},
+		{"gaps", "gap"},
+		{"kiwis", "kiwi"},
+		{"agreed", "agre"},
+		{"humbled", "humbl"},
+		{"sized", "size"},
+		{"hoping", "hope"},
+		{"stating", "state"},
+		{"plotted", "plot"},
+		{"sensational", "sensat"},
+		{"traditional", "tradit"},
+		{"generously", "generous"},
+		{"consign", "consign"},
+		{"consigned", "consign"},
+		{"consignment", "consign"},
+		{"consistency", "consist"},
+		{"consistently", "consist"},
+		{"consolations", "consol"},
+		{"consolatory", "consolatori"},
+		{"consolidating", "consolid"},
+		{"consolingly", "consol"},
+		{"conspicuously", "conspicu"},
+		{"conspiracy", "conspiraci"},
+		{"conspirators", "conspir"},
+		{"constables", "constabl"},
+		{"constancy", "constanc"},
+		{"knackeries", "knackeri"},
+		{"knavish", "knavish"},
+		{"kneeling", "kneel"},
+		{"knightly", "knight"},
+		{"knitting", "knit"},
+		{"knives", "knive"},
+		{"knocker", "knocker"},
+		{"skies", "sky"},
+		{"dying", "die"},
+		{"news", "news"},
+		{"inning", "inning"},
+		{"succeeding", "succeed"},
+		{"yelling", "yell"},
+		{"saying", "say"},
+		{"hank's", "hank"},
+		{"propane", "propan"},
+		{"television", "televis"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Output, Stem(test.Input), test.Input)
+	}
+}
+
+func BenchmarkStem(b *testing.B) {
+	words := []string{"examples", "consolidating", "generously", "knightly", "television"}
+
+	for n := 0; n < b.N; n++ {
+		for _, word := range words {
+			Stem(word)
+		}
+	}
+}
+
+func TestStem_StemGerman(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output string
+	}{
+		{"häuser", "haus"},
+		{"laufen", "lauf"},
+		{"katzen", "katz"},
+		{"kinder", "kind"},
+		{"ergebnisse", "ergebnis"},
+		{"freundlichkeit", "freundlich"},
+		{"möglichkeiten", "moglich"},
+		{"aufeinanderfolgenden", "aufeinanderfolg"},
+		{"zeitung", "zeitung"},
+		{"straße", "strass"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Output, StemGerman(test.Input), test.Input)
+	}
+}
+
+func TestStem_StemFrench(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output string
+	}{
+		{"chevaux", "cheval"},
+		{"maisons", "maison"},
+		{"continuellement", "continuel"},
+		{"nationalité", "national"},
+		{"heureusement", "heureux"},
+		{"finissons", "fin"},
+		{"mangeaient", "mang"},
+		{"grandes", "grand"},
+		{"chanter", "chant"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Output, StemFrench(test.Input), test.Input)
+	}
+}
+
+func TestStem_StemSpanish(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output string
+	}{
+		{"cantaban", "cant"},
+		{"cantar", "cant"},
+		{"niños", "niñ"},
+		{"rápidamente", "rapid"},
+		{"nacionalidad", "nacional"},
+		{"comiéndoselo", "com"},
+		{"organizaciones", "organiz"},
+		{"corriendo", "corr"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Output, StemSpanish(test.Input), test.Input)
+	}
+}
+
+func TestStem_StemItalian(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output string
+	}{
+		{"abbandonata", "abbandon"},
+		{"abbandonare", "abbandon"},
+		{"libri", "libr"},
+		{"parlando", "parl"},
+		{"nazionale", "nazional"},
+		{"velocemente", "veloc"},
+		{"cantavano", "cant"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Output, StemItalian(test.Input), test.Input)
+	}
+}
@@ -0,0 +1,769 @@
+package wally
+
+// germanStopWords are common German words that are not indexed.
+var germanStopWords = map[string]bool{
+	"aber":      true,
+	"alle":      true,
+	"allem":     true,
+	"allen":     true,
+	"aller":     true,
+	"alles":     true,
+	"als":       true,
+	"also":      true,
+	"am":        true,
+	"an":        true,
+	"ander":     true,
+	"andere":    true,
+	"anderem":   true,
+	"anderen":   true,
+	"anderer":   true,
+	"anderes":   true,
+	"anderm":    true,
+	"andern":    true,
+	"anderr":    true,
+	"anders":    true,
+	"auch":      true,
+	"auf":       true,
+	"aus":       true,
+	"bei":       true,
+	"bin":       true,
+	"bis":       true,
+	"bist":      true,
+	"da":        true,
+	"damit":     true,
+	"dann":      true,
+	"der":       true,
+	"den":       true,
+	"des":       true,
+	"dem":       true,
+	"die":       true,
+	"das":       true,
+	"daß":       true,
+	"dass":      true,
+	"derselbe":  true,
+	"derselben": true,
+	"denselben": true,
+	"desselben": true,
+	"demselben": true,
+	"dieselbe":  true,
+	"dieselben": true,
+	"dasselbe":  true,
+	"dazu":      true,
+	"dein":      true,
+	"deine":     true,
+	"deinem":    true,
+	"deinen":    true,
+	"deiner":    true,
+	"deines":    true,
+	"denn":      true,
+	"derer":     true,
+	"dessen":    true,
+	"dich":      true,
+	"dir":       true,
+	"du":        true,
+	"dies":      true,
+	"diese":     true,
+	"diesem":    true,
+	"diesen":    true,
+	"dieser":    true,
+	"dieses":    true,
+	"doch":      true,
+	"dort":      true,
+	"durch":     true,
+	"ein":       true,
+	"eine":      true,
+	"einem":     true,
+	"einen":     true,
+	"einer":     true,
+	"eines":     true,
+	"einig":     true,
+	"einige":    true,
+	"einigem":   true,
+	"einigen":   true,
+	"einiger":   true,
+	"einiges":   true,
+	"einmal":    true,
+	"er":        true,
+	"ihn":       true,
+	"ihm":       true,
+	"es":        true,
+	"etwas":     true,
+	"euer":      true,
+	"eure":      true,
+	"eurem":     true,
+	"euren":     true,
+	"eurer":     true,
+	"eures":     true,
+	"für":       true,
+	"gegen":     true,
+	"gewesen":   true,
+	"hab":       true,
+	"habe":      true,
+	"haben":     true,
+	"hat":       true,
+	"hatte":     true,
+	"hatten":    true,
+	"hier":      true,
+	"hin":       true,
+	"hinter":    true,
+	"ich":       true,
+	"mich":      true,
+	"mir":       true,
+	"ihr":       true,
+	"ihre":      true,
+	"ihrem":     true,
+	"ihren":     true,
+	"ihrer":     true,
+	"ihres":     true,
+	"euch":      true,
+	"im":        true,
+	"in":        true,
+	"indem":     true,
+	"ins":       true,
+	"ist":       true,
+	"jede":      true,
+	"jedem":     true,
+	"jeden":     true,
+	"jeder":     true,
+	"jedes":     true,
+	"jene":      true,
+	"jenem":     true,
+	"jenen":     true,
+	"jener":     true,
+	"jenes":     true,
+	"jetzt":     true,
+	"kann":      true,
+	"kein":      true,
+	"keine":     true,
+	"keinem":    true,
+	"keinen":    true,
+	"keiner":    true,
+	"keines":    true,
+	"können":    true,
+	"könnte":    true,
+	"machen":    true,
+	"man":       true,
+	"manche":    true,
+	"manchem":   true,
+	"manchen":   true,
+	"mancher":   true,
+	"manches":   true,
+	"mein":      true,
+	"meine":     true,
+	"meinem":    true,
+	"meinen":    true,
+	"meiner":    true,
+	"meines":    true,
+	"mit":       true,
+	"muss":      true,
+	"musste":    true,
+	"nach":      true,
+	"nicht":     true,
+	"nichts":    true,
+	"noch":      true,
+	"nun":       true,
+	"nur":       true,
+	"ob":        true,
+	"oder":      true,
+	"ohne":      true,
+	"sehr":      true,
+	"sein":      true,
+	"seine":     true,
+	"seinem":    true,
+	"seinen":    true,
+	"seiner":    true,
+	"seines":    true,
+	"selbst":    true,
+	"sich":      true,
+	"sie":       true,
+	"ihnen":     true,
+	"sind":      true,
+	"so":        true,
+	"solche":    true,
+	"solchem":   true,
+	"solchen":   true,
+	"solcher":   true,
+	"solches":   true,
+	"soll":      true,
+	"sollte":    true,
+	"sondern":   true,
+	"sonst":     true,
+	"über":      true,
+	"um":        true,
+	"und":       true,
+	"uns":       true,
+	"unsere":    true,
+	"unserem":   true,
+	"unseren":   true,
+	"unser":     true,
+	"unseres":   true,
+	"unter":     true,
+	"viel":      true,
+	"vom":       true,
+	"von":       true,
+	"vor":       true,
+	"während":   true,
+	"war":       true,
+	"waren":     true,
+	"warst":     true,
+	"was":       true,
+	"weg":       true,
+	"weil":      true,
+	"weiter":    true,
+	"welche":    true,
+	"welchem":   true,
+	"welchen":   true,
+	"welcher":   true,
+	"welches":   true,
+	"wenn":      true,
+	"werde":     true,
+	"werden":    true,
+	"wie":       true,
+	"wieder":    true,
+	"will":      true,
+	"wir":       true,
+	"wird":      true,
+	"wirst":     true,
+	"wo":        true,
+	"wollen":    true,
+	"wollte":    true,
+	"würde":     true,
+	"würden":    true,
+	"zu":        true,
+	"zum":       true,
+	"zur":       true,
+	"zwar":      true,
+	"zwischen":  true,
+}
+
+// frenchStopWords are common French words that are not indexed.
+var frenchStopWords = map[string]bool{
+	"au":       true,
+	"aux":      true,
+	"avec":     true,
+	"ce":       true,
+	"ces":      true,
+	"dans":     true,
+	"de":       true,
+	"des":      true,
+	"du":       true,
+	"elle":     true,
+	"en":       true,
+	"et":       true,
+	"eux":      true,
+	"il":       true,
+	"je":       true,
+	"la":       true,
+	"le":       true,
+	"leur":     true,
+	"lui":      true,
+	"ma":       true,
+	"mais":     true,
+	"me":       true,
+	"même":     true,
+	"mes":      true,
+	"moi":      true,
+	"mon":      true,
+	"ne":       true,
+	"nos":      true,
+	"notre":    true,
+	"nous":     true,
+	"on":       true,
+	"ou":       true,
+	"par":      true,
+	"pas":      true,
+	"pour":     true,
+	"qu":       true,
+	"que":      true,
+	"qui":      true,
+	"sa":       true,
+	"se":       true,
+	"ses":      true,
+	"son":      true,
+	"sur":      true,
+	"ta":       true,
+	"te":       true,
+	"tes":      true,
+	"toi":      true,
+	"ton":      true,
+	"tu":       true,
+	"un":       true,
+	"une":      true,
+	"vos":      true,
+	"votre":    true,
+	"vous":     true,
+	"c":        true,
+	"d":        true,
+	"j":        true,
+	"l":        true,
+	"à":        true,
+	"m":        true,
+	"n":        true,
+	"s":        true,
+	"t":        true,
+	"y":        true,
+	"été":      true,
+	"étée":     true,
+	"étées":    true,
+	"étés":     true,
+	"étant":    true,
+	"suis":     true,
+	"es":       true,
+	"est":      true,
+	"sommes":   true,
+	"êtes":     true,
+	"sont":     true,
+	"serai":    true,
+	"seras":    true,
+	"sera":     true,
+	"serons":   true,
+	"serez":    true,
+	"seront":   true,
+	"serais":   true,
+	"serait":   true,
+	"serions":  true,
+	"seriez":   true,
+	"seraient": true,
+	"étais":    true,
+	"était":    true,
+	"étions":   true,
+	"étiez":    true,
+	"étaient":  true,
+	"fus":      true,
+	"fut":      true,
+	"fûmes":    true,
+	"fûtes":    true,
+	"furent":   true,
+	"sois":     true,
+	"soit":     true,
+	"soyons":   true,
+	"soyez":    true,
+	"soient":   true,
+	"fusse":    true,
+	"fusses":   true,
+	"fût":      true,
+	"fussions": true,
+	"fussiez":  true,
+	"fussent":  true,
+	"ayant":    true,
+	"eu":       true,
+	"eue":      true,
+	"eues":     true,
+	"eus":      true,
+	"ai":       true,
+	"as":       true,
+	"avons":    true,
+	"avez":     true,
+	"ont":      true,
+	"aurai":    true,
+	"auras":    true,
+	"aura":     true,
+	"aurons":   true,
+	"aurez":    true,
+	"auront":   true,
+	"aurais":   true,
+	"aurait":   true,
+	"aurions":  true,
+	"auriez":   true,
+	"auraient": true,
+	"avais":    true,
+	"avait":    true,
+	"avions":   true,
+	"aviez":    true,
+	"avaient":  true,
+	"eut":      true,
+	"eûmes":    true,
+	"eûtes":    true,
+	"eurent":   true,
+	"aie":      true,
+	"aies":     true,
+	"ait":      true,
+	"ayons":    true,
+	"ayez":     true,
+	"aient":    true,
+	"eusse":    true,
+	"eusses":   true,
+	"eût":      true,
+	"eussions": true,
+	"eussiez":  true,
+	"eussent":  true,
+	"ceci":     true,
+	"cela":     true,
+	"celà":     true,
+	"cet":      true,
+	"cette":    true,
+	"ici":      true,
+	"ils":      true,
+	"les":      true,
+	"leurs":    true,
+	"quel":     true,
+	"quels":    true,
+	"quelle":   true,
+	"quelles":  true,
+	"sans":     true,
+	"soi":      true,
+}
+
+// spanishStopWords are common Spanish words that are not indexed.
+var spanishStopWords = map[string]bool{
+	"de":         true,
+	"la":         true,
+	"que":        true,
+	"el":         true,
+	"en":         true,
+	"y":          true,
+	"a":          true,
+	"los":        true,
+	"del":        true,
+	"se":         true,
+	"las":        true,
+	"por":        true,
+	"un":         true,
+	"para":       true,
+	"con":        true,
+	"no":         true,
+	"una":        true,
+	"su":         true,
+	"al":         true,
+	"lo":         true,
+	"como":       true,
+	"más":        true,
+	"pero":       true,
+	"sus":        true,
+	"le":         true,
+	"ya":         true,
+	"o":          true,
+	"este":       true,
+	"sí":         true,
+	"porque":     true,
+	"esta":       true,
+	"entre":      true,
+	"cuando":     true,
+	"muy":        true,
+	"sin":        true,
+	"sobre":      true,
+	"también":    true,
+	"me":         true,
+	"hasta":      true,
+	"hay":        true,
+	"donde":      true,
+	"quien":      true,
+	"desde":      true,
+	"todo":       true,
+	"nos":        true,
+	"durante":    true,
+	"todos":      true,
+	"uno":        true,
+	"les":        true,
+	"ni":         true,
+	"contra":     true,
+	"otros":      true,
+	"ese":        true,
+	"eso":        true,
+	"ante":       true,
+	"ellos":      true,
+	"e":          true,
+	"esto":       true,
+	"mí":         true,
+	"antes":      true,
+	"algunos":    true,
+	"qué":        true,
+	"unos":       true,
+	"yo":         true,
+	"otro":       true,
+	"otras":      true,
+	"otra":       true,
+	"él":         true,
+	"tanto":      true,
+	"esa":        true,
+	"estos":      true,
+	"mucho":      true,
+	"quienes":    true,
+	"nada":       true,
+	"muchos":     true,
+	"cual":       true,
+	"poco":       true,
+	"ella":       true,
+	"estar":      true,
+	"estas":      true,
+	"algunas":    true,
+	"algo":       true,
+	"nosotros":   true,
+	"mi":         true,
+	"mis":        true,
+	"tú":         true,
+	"te":         true,
+	"ti":         true,
+	"tu":         true,
+	"tus":        true,
+	"ellas":      true,
+	"nosotras":   true,
+	"vosotros":   true,
+	"vosotras":   true,
+	"os":         true,
+	"mío":        true,
+	"mía":        true,
+	"míos":       true,
+	"mías":       true,
+	"tuyo":       true,
+	"tuya":       true,
+	"tuyos":      true,
+	"tuyas":      true,
+	"suyo":       true,
+	"suya":       true,
+	"suyos":      true,
+	"suyas":      true,
+	"nuestro":    true,
+	"nuestra":    true,
+	"nuestros":   true,
+	"nuestras":   true,
+	"vuestro":    true,
+	"vuestra":    true,
+	"vuestros":   true,
+	"vuestras":   true,
+	"esos":       true,
+	"esas":       true,
+	"estoy":      true,
+	"estás":      true,
+	"está":       true,
+	"estamos":    true,
+	"estáis":     true,
+	"están":      true,
+	"esté":       true,
+	"estés":      true,
+	"estemos":    true,
+	"estéis":     true,
+	"estén":      true,
+	"estaba":     true,
+	"estabas":    true,
+	"estábamos":  true,
+	"estabais":   true,
+	"estaban":    true,
+	"estuve":     true,
+	"estuvo":     true,
+	"estuvimos":  true,
+	"estuvieron": true,
+	"he":         true,
+	"has":        true,
+	"ha":         true,
+	"hemos":      true,
+	"habéis":     true,
+	"han":        true,
+	"haya":       true,
+	"hayas":      true,
+	"hayamos":    true,
+	"hayan":      true,
+	"había":      true,
+	"habías":     true,
+	"habíamos":   true,
+	"habían":     true,
+	"hube":       true,
+	"hubo":       true,
+	"soy":        true,
+	"eres":       true,
+	"es":         true,
+	"somos":      true,
+	"sois":       true,
+	"son":        true,
+	"sea":        true,
+	"seas":       true,
+	"seamos":     true,
+	"sean":       true,
+	"era":        true,
+	"eras":       true,
+	"éramos":     true,
+	"erais":      true,
+	"eran":       true,
+	"fui":        true,
+	"fue":        true,
+	"fuimos":     true,
+	"fueron":     true,
+	"tengo":      true,
+	"tienes":     true,
+	"tiene":      true,
+	"tenemos":    true,
+	"tienen":     true,
+	"tenía":      true,
+	"tenían":     true,
+	"tuve":       true,
+	"tuvo":       true,
+}
+
+// italianStopWords are common Italian words that are not indexed.
+var italianStopWords = map[string]bool{
+	"ad":      true,
+	"al":      true,
+	"allo":    true,
+	"ai":      true,
+	"agli":    true,
+	"all":     true,
+	"agl":     true,
+	"alla":    true,
+	"alle":    true,
+	"con":     true,
+	"col":     true,
+	"coi":     true,
+	"da":      true,
+	"dal":     true,
+	"dallo":   true,
+	"dai":     true,
+	"dagli":   true,
+	"dall":    true,
+	"dagl":    true,
+	"dalla":   true,
+	"dalle":   true,
+	"di":      true,
+	"del":     true,
+	"dello":   true,
+	"dei":     true,
+	"degli":   true,
+	"dell":    true,
+	"degl":    true,
+	"della":   true,
+	"delle":   true,
+	"in":      true,
+	"nel":     true,
+	"nello":   true,
+	"nei":     true,
+	"negli":   true,
+	"nell":    true,
+	"negl":    true,
+	"nella":   true,
+	"nelle":   true,
+	"su":      true,
+	"sul":     true,
+	"sullo":   true,
+	"sui":     true,
+	"sugli":   true,
+	"sull":    true,
+	"sugl":    true,
+	"sulla":   true,
+	"sulle":   true,
+	"per":     true,
+	"tra":     true,
+	"contro":  true,
+	"io":      true,
+	"tu":      true,
+	"lui":     true,
+	"lei":     true,
+	"noi":     true,
+	"voi":     true,
+	"loro":    true,
+	"mio":     true,
+	"mia":     true,
+	"miei":    true,
+	"mie":     true,
+	"tuo":     true,
+	"tua":     true,
+	"tuoi":    true,
+	"tue":     true,
+	"suo":     true,
+	"sua":     true,
+	"suoi":    true,
+	"sue":     true,
+	"nostro":  true,
+	"nostra":  true,
+	"nostri":  true,
+	"nostre":  true,
+	"vostro":  true,
+	"vostra":  true,
+	"vostri":  true,
+	"vostre":  true,
+	"mi":      true,
+	"ti":      true,
+	"ci":      true,
+	"vi":      true,
+	"lo":      true,
+	"la":      true,
+	"li":      true,
+	"le":      true,
+	"gli":     true,
+	"ne":      true,
+	"il":      true,
+	"un":      true,
+	"uno":     true,
+	"una":     true,
+	"ma":      true,
+	"ed":      true,
+	"se":      true,
+	"perché":  true,
+	"anche":   true,
+	"come":    true,
+	"dov":     true,
+	"dove":    true,
+	"che":     true,
+	"chi":     true,
+	"cui":     true,
+	"non":     true,
+	"più":     true,
+	"quale":   true,
+	"quanto":  true,
+	"quanti":  true,
+	"quanta":  true,
+	"quante":  true,
+	"quello":  true,
+	"quelli":  true,
+	"quella":  true,
+	"quelle":  true,
+	"questo":  true,
+	"questi":  true,
+	"questa":  true,
+	"queste":  true,
+	"si":      true,
+	"tutto":   true,
+	"tutti":   true,
+	"a":       true,
+	"c":       true,
+	"e":       true,
+	"i":       true,
+	"l":       true,
+	"o":       true,
+	"ho":      true,
+	"hai":     true,
+	"ha":      true,
+	"abbiamo": true,
+	"avete":   true,
+	"hanno":   true,
+	"abbia":   true,
+	"abbiate": true,
+	"abbiano": true,
+	"avevo":   true,
+	"avevi":   true,
+	"aveva":   true,
+	"avevamo": true,
+	"avevate": true,
+	"avevano": true,
+	"ebbi":    true,
+	"ebbe":    true,
+	"ebbero":  true,
+	"sono":    true,
+	"sei":     true,
+	"è":       true,
+	"siamo":   true,
+	"siete":   true,
+	"era":     true,
+	"ero":     true,
+	"eri":     true,
+	"eravamo": true,
+	"eravate": true,
+	"erano":   true,
+	"fui":     true,
+	"fu":      true,
+	"fummo":   true,
+	"furono":  true,
+	"sia":     true,
+	"siate":   true,
+	"siano":   true,
+	"sarò":    true,
+	"sarai":   true,
+	"sarà":    true,
+	"saremo":  true,
+	"sarete":  true,
+	"saranno": true,
+	"stato":   true,
+	"stata":   true,
+	"stati":   true,
+	"state":   true,
+	"essere":  true,
+	"avere":   true,
+}
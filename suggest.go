@@ -0,0 +1,168 @@
+package wally
+
+import (
+	"sort"
+	"strings"
+)
+
+// suggest returns a spelling correction for a query that found nothing, each
+// word of the query that isn't indexed is replaced by the closest indexed
+// word, the one found in the most documents when several are as close. The
+// rest of the query is left as it was so the suggestion can be searched for as
+// it is. An empty string is returned when there's nothing to correct or the
+// corrected query doesn't find anything either.
+func suggest(query string, store Store) (string, error) {
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return "", err
+	}
+
+	corrected := ""
+	changed := false
+	last := 0
+	field := ""
+	for _, t := range tokens {
+		if t.Kind != wordToken {
+			if t.Kind == fieldToken {
+				field = strings.ToLower(strings.TrimSuffix(t.Text, ":"))
+			} else {
+				field = ""
+			}
+			continue
+		}
+
+		word, err := correctWord(t.Text, field, store)
+		if err != nil {
+			return "", err
+		}
+		field = ""
+		if word == "" {
+			continue
+		}
+
+		corrected += query[last:t.Offset] + word
+		last = t.Offset + len(t.Text)
+		changed = true
+	}
+	if !changed {
+		return "", nil
+	}
+	corrected += query[last:]
+
+	res, err := search(corrected, store, 1)
+	if err != nil || res.Count == 0 {
+		return "", err
+	}
+	return corrected, nil
+}
+
+// correctWord returns the indexed word closest to a word of a query in field,
+// or an empty string when the word is indexed, can't be corrected or isn't a
+// plain word. Words in a field indexed as n-grams aren't corrected.
+func correctWord(text, field string, store Store) (string, error) {
+	if isWildcard(text) || fuzzyRegex.MatchString(text) || isNGramField(field) {
+		return "", nil
+	}
+
+	words := SplitTextIntoWords(text)
+	if len(words) != 1 {
+		return "", nil
+	}
+	terms := QueryTerms(words[0])
+	if len(terms) == 0 {
+		return "", nil
+	}
+
+	count, err := store.CountIndexes(fieldTerms(field, terms))
+	if err != nil || count > 0 {
+		return "", err
+	}
+
+	// Short words are easily mistaken for other short words
+	distance := 2
+	if len([]rune(words[0])) <= 4 {
+		distance = 1
+	}
+	if max := config().Expansion.MaxEdits; distance > max {
+		distance = max
+	}
+
+	f := &fuzzy{term: term{Field: field}, Words: terms, Distance: distance}
+	if err := f.expand(store); err != nil {
+		return "", err
+	}
+
+	candidates := make([]suggestion, 0, len(f.Terms))
+	for _, c := range f.Terms {
+		frequency, err := store.CountIndexes([]string{fieldTerm(field, c)})
+		if err != nil {
+			return "", err
+		}
+
+		s := suggestion{Term: c, Distance: -1, Frequency: frequency}
+		for _, t := range terms {
+			if d := editDistance(t, c); s.Distance < 0 || d < s.Distance {
+				s.Distance = d
+			}
+		}
+		candidates = append(candidates, s)
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	sort.Sort(bySuggestion(candidates))
+
+	return surfaceWord(candidates[0].Term, store)
+}
+
+// surfaceWord returns the word a term is most often written as when surface
+// forms are kept, otherwise the term itself, which is found by searching for
+// it.
+func surfaceWord(term string, store Store) (string, error) {
+	if !config().Analysis.KeepSurface {
+		return term, nil
+	}
+
+	indexes, err := store.GetIndexes(fieldTerms("", []string{term}))
+	if err != nil {
+		return "", err
+	}
+
+	counts := map[string]int64{}
+	best := term
+	for _, index := range indexes {
+		if index.Surface == "" {
+			continue
+		}
+		counts[index.Surface] += index.Count
+		if c := counts[index.Surface]; c > counts[best] || (c == counts[best] && index.Surface < best) {
+			best = index.Surface
+		}
+	}
+	return best, nil
+}
+
+// suggestion is an indexed term that could replace a misspelt word, Distance is
+// the number of edits between them and Frequency the number of documents the
+// term is found in, in the content when the word isn't limited to a field.
+type suggestion struct {
+	Term      string
+	Distance  int
+	Frequency int64
+}
+
+// bySuggestion orders suggestions closest first and then by how common they
+// are.
+type bySuggestion []suggestion
+
+func (b bySuggestion) Len() int      { return len(b) }
+func (b bySuggestion) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b bySuggestion) Less(i, j int) bool {
+	if b[i].Distance != b[j].Distance {
+		return b[i].Distance < b[j].Distance
+	}
+	if b[i].Frequency != b[j].Frequency {
+		return b[i].Frequency > b[j].Frequency
+	}
+	return b[i].Term < b[j].Term
+}
@@ -0,0 +1,79 @@
+package wally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggest_suggest(t *testing.T) {
+	defer tearDbDown(store)
+
+	docs := []*Document{
+		{ID: "1", Title: "Ernest Rutherford", Content: "Rutherford split the atom"},
+		{ID: "2", Content: "Hank sells propane and propane accessories"},
+		{ID: "3", Content: "Hank drinks beer in the alley"},
+		{ID: "4", Content: "Bear country"},
+	}
+	for _, doc := range docs {
+		assert.NoError(t, doc.Put(store))
+		assert.NoError(t, IndexBatchPut(store, IndexDocument(doc)))
+	}
+
+	tests := []struct {
+		Query      string
+		Suggestion string
+	}{
+		{"rutherfrod", "rutherford"},
+		{"Hank sells porpane", "Hank sells propan"},
+		{`+hank -bere "split atom"`, `+hank -beer "split atom"`},
+		{"title:rutherfrod", "title:rutherford"},
+		{"author:rutherfrod", ""},
+		{"hank AND rutherford", ""},
+		{"qwertyuiop", ""},
+		{"propan* zzz~1", ""},
+		{"the", ""},
+	}
+
+	for _, test := range tests {
+		suggestion, err := suggest(test.Query, store)
+		assert.NoError(t, err, test.Query)
+		assert.Equal(t, test.Suggestion, suggestion, test.Query)
+	}
+}
+
+func TestSuggest_correctWordFrequency(t *testing.T) {
+	defer tearDbDown(store)
+
+	docs := []*Document{
+		{ID: "1", Content: "beer"},
+		{ID: "2", Content: "beer"},
+		{ID: "3", Content: "bear"},
+	}
+	for _, doc := range docs {
+		assert.NoError(t, IndexBatchPut(store, IndexDocument(doc)))
+	}
+
+	// Both are one edit away, beer is in more documents
+	word, err := correctWord("beor", "", store)
+	assert.NoError(t, err)
+	assert.Equal(t, "beer", word)
+
+	word, err = correctWord("bear", "", store)
+	assert.NoError(t, err)
+	assert.Equal(t, "", word)
+}
+
+func TestSuggest_correctWordSurface(t *testing.T) {
+	defer tearDbDown(store)
+
+	Conf.Analysis.KeepSurface = true
+	defer func() { Conf.Analysis.KeepSurface = false }()
+
+	doc := &Document{ID: "1", Content: "Hank sells propane and propane accessories"}
+	assert.NoError(t, IndexBatchPut(store, IndexDocument(doc)))
+
+	word, err := correctWord("porpane", "", store)
+	assert.NoError(t, err)
+	assert.Equal(t, "propane", word)
+}
@@ -0,0 +1,177 @@
+package wally
+
+import (
+	"errors"
+	"strings"
+)
+
+// synonymSet maps words of a query, case folded and joined by single spaces,
+// to the words and phrases a query for them also matches. Longest is the most
+// words any of the keys has.
+type synonymSet struct {
+	synonyms map[string][]string
+	longest  int
+}
+
+func newSynonymSet() *synonymSet {
+	return &synonymSet{synonyms: map[string][]string{}}
+}
+
+// add adds a rule, either a group of equivalent words and phrases separated by
+// commas, such as "propane, lpg, lp gas", each of which matches all the others,
+// or one-way synonyms such as "lp gas => propane" where the words on the left
+// also match those on the right but not the other way round.
+func (s *synonymSet) add(rule string) error {
+	parts := strings.Split(rule, "=>")
+	switch len(parts) {
+	case 1:
+		group := synonymList(parts[0])
+		if len(group) < 2 {
+			return errors.New("a synonym group needs at least two entries")
+		}
+		for _, key := range group {
+			s.put(key, group)
+		}
+	case 2:
+		from, to := synonymList(parts[0]), synonymList(parts[1])
+		if len(from) == 0 || len(to) == 0 {
+			return errors.New("one-way synonyms need entries either side of =>")
+		}
+		for _, key := range from {
+			s.put(key, to)
+		}
+	default:
+		return errors.New("one-way synonyms can only have one =>")
+	}
+	return nil
+}
+
+// put adds synonyms for key, leaving out key itself and any it already has.
+func (s *synonymSet) put(key string, synonyms []string) {
+	for _, synonym := range synonyms {
+		if synonym != key && !containsString(s.synonyms[key], synonym) {
+			s.synonyms[key] = append(s.synonyms[key], synonym)
+		}
+	}
+	if n := len(strings.Fields(key)); n > s.longest {
+		s.longest = n
+	}
+}
+
+// lookup returns the synonyms of a run of words.
+func (s *synonymSet) lookup(words []string) []string {
+	return s.synonyms[strings.Join(words, " ")]
+}
+
+// synonymList splits a list of words and phrases separated by commas, each is
+// case folded and split into words.
+func synonymList(list string) []string {
+	entries := []string{}
+	for _, entry := range strings.Split(list, ",") {
+		if words := synonymWords(entry); len(words) > 0 {
+			entries = append(entries, strings.Join(words, " "))
+		}
+	}
+	return entries
+}
+
+// synonymWords returns the words of text as they're matched against synonyms,
+// case folded but not stemmed and with stop words kept.
+func synonymWords(text string) []string {
+	return SplitTextIntoWords(FoldCase(text))
+}
+
+// isPlainWord reports whether a word of a query can have synonyms, wildcard
+// patterns and fuzzy words can't.
+func isPlainWord(text string) bool {
+	return !isWildcard(text) && !fuzzyRegex.MatchString(text)
+}
+
+// synonymRun finds the longest run of plain words of the query, starting with
+// the word t that's just been taken, with synonyms. The rest of the run's words
+// are taken and the text of each word is returned along with the synonyms, or
+// nil when there are none.
+func (p *queryParser) synonymRun(t token) ([]string, []string) {
+	set := config().Synonyms.set
+	if set == nil || !isPlainWord(t.Text) {
+		return nil, nil
+	}
+
+	texts := []string{t.Text}
+	words := synonymWords(t.Text)
+	length, synonyms := 1, set.lookup(words)
+
+	for i := p.pos; len(words) < set.longest; i++ {
+		next := p.tokens[i]
+		if next.Kind != wordToken || !isPlainWord(next.Text) {
+			break
+		}
+		texts = append(texts, next.Text)
+		words = append(words, synonymWords(next.Text)...)
+
+		// A word followed by AND, OR or NEAR/n belongs to them so the run
+		// can't end on it
+		switch p.tokens[i+1].Kind {
+		case andToken, orToken, nearToken:
+			continue
+		}
+		if found := set.lookup(words); found != nil {
+			length, synonyms = len(texts), found
+		}
+	}
+
+	if synonyms == nil {
+		return nil, nil
+	}
+	p.pos += length - 1
+	return texts[:length], synonyms
+}
+
+// synonymNode returns the matcher for a run of words of a query in field that
+// has synonyms. The words match what they would on their own and documents
+// matching any of the synonyms are matched as well. A single word with single
+// word synonyms is searched for as any of their terms.
+func synonymNode(texts, synonyms []string, field string) node {
+	nodes := clauseNode{}
+	for _, text := range texts {
+		if n := wordNode(text, field); n != nil {
+			nodes = append(nodes, n)
+		}
+	}
+
+	alternatives := orNode{}
+	switch len(nodes) {
+	case 0:
+	case 1:
+		alternatives = append(alternatives, nodes[0])
+	default:
+		alternatives = append(alternatives, nodes)
+	}
+
+	for _, synonym := range synonyms {
+		n := phraseNode(synonym, field)
+		if n == nil {
+			continue
+		}
+
+		if t, ok := n.(*term); ok && len(alternatives) > 0 {
+			if word, ok := alternatives[0].(*term); ok {
+				for _, term := range t.Terms {
+					if !containsString(word.Terms, term) {
+						word.Terms = append(word.Terms, term)
+					}
+				}
+				continue
+			}
+		}
+		alternatives = append(alternatives, n)
+	}
+
+	switch len(alternatives) {
+	case 0:
+		return nil
+	case 1:
+		return alternatives[0]
+	}
+	return alternatives
+}
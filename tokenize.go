@@ -0,0 +1,111 @@
+package wally
+
+import "unicode"
+
+// Tokenize splits text into words following the word boundary rules of Unicode
+// Standard Annex #29 (http://unicode.org/reports/tr29/), closely enough for
+// indexing. Punctuation and whitespace separate words and are dropped, so
+// "(fictional)" and "Texas," give "fictional" and "Texas".
+//
+// A few characters are kept when they fall inside a word:
+//
+// - apostrophes between letters, so "don't" and "Hank's" are single words,
+// curly apostrophes are straightened
+// - full stops between letters or digits, so "U.S.A" and "3.14" are kept whole
+// - commas between digits, so "1,000" is one number
+// - underscores anywhere in a word
+//
+// Hyphens always split words, so "well-known" is indexed as "well" and "known"
+// and a search for either finds it. Letters and digits run together, as in
+// "mp3". Han ideographs and Hiragana are written without spaces so each one is
+// a word of its own.
+func Tokenize(text string) []string {
+	spans := tokenSpans(text)
+	words := make([]string, len(spans))
+	for i, span := range spans {
+		words[i] = span.Word
+	}
+	return words
+}
+
+// tokenSpan is a word found by Tokenize, Start and End are the byte offsets of
+// the text it was taken from.
+type tokenSpan struct {
+	Word       string
+	Start, End int
+}
+
+// tokenSpans splits text into words like Tokenize, also giving where in text
+// each word is found.
+func tokenSpans(text string) []tokenSpan {
+	runes := []rune(text)
+	offsets := make([]int, 0, len(runes)+1)
+	for i := range text {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(text))
+
+	spans := []tokenSpan{}
+
+	word := []rune{}
+	start := 0
+	flush := func(end int) {
+		if len(word) > 0 {
+			spans = append(spans, tokenSpan{string(word), offsets[start], offsets[end]})
+		}
+		word = word[:0]
+	}
+
+	for i, r := range runes {
+		switch {
+		case isIdeograph(r):
+			flush(i)
+			spans = append(spans, tokenSpan{string(r), offsets[i], offsets[i+1]})
+		case isWordRune(r):
+			// A word can't begin with a combining mark.
+			if len(word) == 0 && unicode.IsMark(r) {
+				continue
+			}
+			if len(word) == 0 {
+				start = i
+			}
+			word = append(word, r)
+		case len(word) > 0 && i+1 < len(runes) && joinsWord(runes[i-1], r, runes[i+1]):
+			if r == '’' {
+				r = '\''
+			}
+			word = append(word, r)
+		default:
+			flush(i)
+		}
+	}
+	flush(len(runes))
+
+	return spans
+}
+
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.In(r, unicode.L, unicode.N, unicode.M, unicode.Pc) && !isIdeograph(r)
+}
+
+func isLetter(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsMark(r)) && !isIdeograph(r)
+}
+
+// joinsWord reports whether r, found between prev and next, is part of a word
+// rather than punctuation separating two.
+func joinsWord(prev, r, next rune) bool {
+	switch r {
+	case '\'', '’':
+		return isLetter(prev) && isLetter(next)
+	case '.':
+		return (isLetter(prev) && isLetter(next)) || (unicode.IsDigit(prev) && unicode.IsDigit(next))
+	case ',':
+		return unicode.IsDigit(prev) && unicode.IsDigit(next)
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package wally
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize_Tokenize(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output []string
+	}{
+		{"Texas, Texas. (Texas) \"Texas\"", []string{"Texas", "Texas", "Texas", "Texas"}},
+		{"Hank's friends' beer", []string{"Hank's", "friends", "beer"}},
+		{"don’t 'quoted'", []string{"don't", "quoted"}},
+		{"well-known state-of-the-art", []string{"well", "known", "state", "of", "the", "art"}},
+		{"U.S.A. in 1997.", []string{"U.S.A", "in", "1997"}},
+		{"3.14 and 1,000, or 5.", []string{"3.14", "and", "1,000", "or", "5"}},
+		{"mp3 snake_case", []string{"mp3", "snake_case"}},
+		{"Größe café naïve", []string{"Größe", "café", "naïve"}},
+		{"l'école", []string{"l'école"}},
+		{"Привет, мир!", []string{"Привет", "мир"}},
+		{"東京タワー", []string{"東", "京", "タワー"}},
+		{"café ́x", []string{"café", "x"}},
+		{"... --- !!!", []string{}},
+		{"", []string{}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Output, Tokenize(test.Input), test.Input)
+	}
+}
+
+func TestTokenize_tokenSpans(t *testing.T) {
+	text := "Hank’s café, 東京"
+	spans := tokenSpans(text)
+
+	assert.Equal(t, []tokenSpan{
+		{"Hank's", 0, 8},
+		{"café", 9, 14},
+		{"東", 16, 19},
+		{"京", 19, 22},
+	}, spans)
+	assert.Equal(t, "Hank’s", text[spans[0].Start:spans[0].End])
+	assert.Equal(t, "café", text[spans[1].Start:spans[1].End])
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	data, err := ioutil.ReadFile("test_data/test.txt")
+	if err != nil {
+		b.Error("Could not load test data")
+	}
+	text := string(data)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		Tokenize(text)
+	}
+}
@@ -0,0 +1,363 @@
+package wally
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// trie holds a set of words so that words sharing a prefix share nodes, which
+// makes finding every word starting with a prefix, or within a few edits of
+// another word, quick without looking at each word in turn. It's compact, a
+// run of characters with no branches is kept on a single node.
+//
+// Each word has a frequency, the number of documents it's found in, and each
+// node knows the highest frequency below it so the most frequent words
+// starting with a prefix are found without visiting the rest.
+type trie struct {
+	root *trieNode
+}
+
+type trieNode struct {
+	// label holds the characters between the node's parent and the node,
+	// children are keyed by the first character of their label.
+	label    string
+	children map[rune]*trieNode
+
+	word      bool
+	frequency int64
+	best      int64
+}
+
+func newTrie() *trie {
+	return &trie{root: &trieNode{}}
+}
+
+// insert adds word to the trie, adding a word twice counts it twice.
+func (t *trie) insert(word string) {
+	t.add(word, 1)
+}
+
+// add changes the frequency of word by delta, the word is added if it's
+// missing and removed once its frequency drops to 0.
+func (t *trie) add(word string, delta int64) {
+	if delta == 0 {
+		return
+	}
+
+	n := t.root
+	path := []*trieNode{n}
+
+	rest := word
+	for rest != "" {
+		r, _ := utf8.DecodeRuneInString(rest)
+		child, ok := n.children[r]
+		if !ok {
+			if delta <= 0 {
+				return
+			}
+			child = &trieNode{label: rest}
+			n.setChild(child)
+			n = child
+			path = append(path, n)
+			break
+		}
+
+		common := commonPrefix(rest, child.label)
+		if common < len(child.label) {
+			if delta <= 0 {
+				return
+			}
+
+			// The word leaves the child's label part way through, so
+			// the label is split in two.
+			split := &trieNode{label: child.label[:common]}
+			child.label = child.label[common:]
+			split.setChild(child)
+			n.setChild(split)
+			child = split
+		}
+
+		n = child
+		path = append(path, n)
+		rest = rest[common:]
+	}
+
+	n.frequency += delta
+	n.word = n.frequency > 0
+	if !n.word {
+		n.frequency = 0
+	}
+
+	// Walk back up, dropping nodes that no longer lead to a word, joining
+	// nodes with a single child to it and updating the highest frequencies.
+	for i := len(path) - 1; i > 0; i-- {
+		node, parent := path[i], path[i-1]
+		switch {
+		case !node.word && len(node.children) == 0:
+			delete(parent.children, firstRune(node.label))
+		case !node.word && len(node.children) == 1:
+			for _, child := range node.children {
+				child.label = node.label + child.label
+				parent.setChild(child)
+			}
+		default:
+			node.updateBest()
+		}
+	}
+	t.root.updateBest()
+}
+
+// set changes the frequency of word to frequency, the word is removed when
+// it's 0.
+func (t *trie) set(word string, frequency int64) {
+	var current int64
+	if n, extra := t.find(word); n != nil && extra == "" && n.word {
+		current = n.frequency
+	}
+	t.add(word, frequency-current)
+}
+
+func (n *trieNode) setChild(child *trieNode) {
+	if n.children == nil {
+		n.children = map[rune]*trieNode{}
+	}
+	n.children[firstRune(child.label)] = child
+}
+
+func (n *trieNode) updateBest() {
+	n.best = n.frequency
+	for _, child := range n.children {
+		if child.best > n.best {
+			n.best = child.best
+		}
+	}
+}
+
+// find returns the node for the shortest word starting with prefix, along with
+// the characters of the node's label past the end of prefix, or nil when no
+// word starts with prefix.
+func (t *trie) find(prefix string) (*trieNode, string) {
+	n := t.root
+	rest := prefix
+	for rest != "" {
+		r, _ := utf8.DecodeRuneInString(rest)
+		child, ok := n.children[r]
+		if !ok {
+			return nil, ""
+		}
+
+		common := commonPrefix(rest, child.label)
+		switch {
+		case common == len(rest):
+			return child, child.label[common:]
+		case common < len(child.label):
+			return nil, ""
+		}
+		n = child
+		rest = rest[common:]
+	}
+	return n, ""
+}
+
+// withPrefix returns every word starting with prefix, in order.
+func (t *trie) withPrefix(prefix string) []string {
+	words := []string{}
+	if n, extra := t.find(prefix); n != nil {
+		n.collect(prefix+extra, &words)
+	}
+	sort.Strings(words)
+	return words
+}
+
+func (n *trieNode) collect(word string, words *[]string) {
+	if n.word {
+		*words = append(*words, word)
+	}
+	for _, child := range n.children {
+		child.collect(word+child.label, words)
+	}
+}
+
+// top returns the n most frequent words starting with prefix, most frequent
+// first and then in order. Unless colons is set words with a colon after prefix
+// are left out, indexed words like that belong to another field.
+func (t *trie) top(prefix string, n int, colons bool) []Completion {
+	completions := []Completion{}
+
+	node, extra := t.find(prefix)
+	if node == nil || (!colons && strings.Contains(extra, ":")) {
+		return completions
+	}
+
+	// Nodes are visited most frequent first, a word is only taken once no
+	// node left to visit could lead to a more frequent one.
+	q := &completionQueue{{node: node, word: prefix + extra, priority: node.best}}
+	for q.Len() > 0 && len(completions) < n {
+		c := heap.Pop(q).(completionItem)
+		if c.node == nil {
+			completions = append(completions, Completion{Word: c.word, Frequency: c.priority})
+			continue
+		}
+
+		if c.node.word {
+			heap.Push(q, completionItem{word: c.word, priority: c.node.frequency})
+		}
+		for _, child := range c.node.children {
+			if colons || !strings.Contains(child.label, ":") {
+				heap.Push(q, completionItem{node: child, word: c.word + child.label, priority: child.best})
+			}
+		}
+	}
+	return completions
+}
+
+// completionItem is a node to visit, or when node is nil a word to take.
+type completionItem struct {
+	node     *trieNode
+	word     string
+	priority int64
+}
+
+// completionQueue orders items by priority and then word, every word below a
+// node starts with the node's word, so taking words before nodes when they're
+// equal keeps words in order.
+type completionQueue []completionItem
+
+func (q completionQueue) Len() int      { return len(q) }
+func (q completionQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q completionQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	if q[i].word != q[j].word {
+		return q[i].word < q[j].word
+	}
+	return q[i].node == nil
+}
+
+func (q *completionQueue) Push(x interface{}) { *q = append(*q, x.(completionItem)) }
+func (q *completionQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// fuzzy returns every word starting with prefix whose remainder is no more than
+// distance edits from word, in order. An edit is inserting, removing or
+// changing a character, or swapping two characters next to each other.
+//
+// The distance to word is worked out a row at a time as the trie is walked, so
+// words sharing a prefix share the work, and a branch is left as soon as no
+// word below it can be close enough.
+func (t *trie) fuzzy(prefix, word string, distance int) []string {
+	words := []string{}
+
+	n, extra := t.find(prefix)
+	if n == nil {
+		return words
+	}
+
+	f := &fuzzyWalk{
+		target:   []rune(word),
+		distance: distance,
+		words:    &words,
+	}
+
+	row := make([]int, len(f.target)+1)
+	for i := range row {
+		row[i] = i
+	}
+	f.walk(n, []rune(extra), []rune(prefix), nil, row)
+
+	sort.Strings(words)
+	return words
+}
+
+// fuzzyWalk holds the state of a search for the words close to target, each
+// row holds the distances from the word so far to each prefix of target.
+type fuzzyWalk struct {
+	target   []rune
+	distance int
+	words    *[]string
+}
+
+// walk follows label to n, working out a row for each of its characters, and
+// then walks each of n's children.
+func (f *fuzzyWalk) walk(n *trieNode, label, word []rune, before, row []int) {
+	for _, r := range label {
+		if f.hopeless(before, row) {
+			return
+		}
+		before, row = row, f.next(r, word, before, row)
+		word = append(word, r)
+	}
+
+	if n.word && row[len(f.target)] <= f.distance {
+		*f.words = append(*f.words, string(word))
+	}
+	if f.hopeless(before, row) {
+		return
+	}
+
+	for _, child := range n.children {
+		f.walk(child, []rune(child.label), word, before, row)
+	}
+}
+
+// next returns the row for word followed by r.
+func (f *fuzzyWalk) next(r rune, word []rune, before, row []int) []int {
+	next := make([]int, len(row))
+	next[0] = row[0] + 1
+	for j := 1; j < len(next); j++ {
+		cost := 1
+		if f.target[j-1] == r {
+			cost = 0
+		}
+		next[j] = minInt(row[j]+1, next[j-1]+1, row[j-1]+cost)
+
+		// Swapping r with the character before it
+		if before != nil && j > 1 && r == f.target[j-2] && word[len(word)-1] == f.target[j-1] {
+			next[j] = minInt(next[j], before[j-2]+1)
+		}
+	}
+	return next
+}
+
+// hopeless reports whether no word continuing from row can be close enough,
+// every distance after it is at least the smallest in the row, or one more than
+// the smallest in the row before it when characters are swapped.
+func (f *fuzzyWalk) hopeless(before, row []int) bool {
+	return minInt(row...) > f.distance && (before == nil || minInt(before...)+1 > f.distance)
+}
+
+// commonPrefix returns the length in bytes of the longest prefix a and b share,
+// without splitting a character.
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) {
+		r, size := utf8.DecodeRuneInString(a[i:])
+		if s, _ := utf8.DecodeRuneInString(b[i:]); r != s {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
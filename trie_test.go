@@ -0,0 +1,171 @@
+package wally
+
+import (
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrie_withPrefix(t *testing.T) {
+	tr := newTrie()
+	for _, word := range []string{"propan", "prop", "propan", "hank", "title:propan", "café"} {
+		tr.insert(word)
+	}
+
+	assert.Equal(t, []string{"prop", "propan"}, tr.withPrefix("prop"))
+	assert.Equal(t, []string{"title:propan"}, tr.withPrefix("title:"))
+	assert.Equal(t, []string{"café"}, tr.withPrefix("caf"))
+	assert.Equal(t, []string{"café", "hank", "prop", "propan", "title:propan"}, tr.withPrefix(""))
+	assert.Equal(t, []string{}, tr.withPrefix("beer"))
+}
+
+func TestTrie_top(t *testing.T) {
+	tr := newTrie()
+	for _, word := range []string{"propan", "prop", "propan", "proper", "proper", "hank", "title:propan", "pro:x"} {
+		tr.insert(word)
+	}
+
+	assert.Equal(t, []Completion{{"propan", 2}, {"proper", 2}, {"prop", 1}}, tr.top("pro", 10, false))
+	assert.Equal(t, []Completion{{"propan", 2}}, tr.top("pro", 1, false))
+	assert.Equal(t, []Completion{{"propan", 2}, {"proper", 2}, {"pro:x", 1}, {"prop", 1}}, tr.top("pro", 10, true))
+	assert.Equal(t, []Completion{{"title:propan", 1}}, tr.top("title:", 10, false))
+	assert.Equal(t, []Completion{}, tr.top("ti", 10, false))
+	assert.Equal(t, []Completion{}, tr.top("beer", 10, false))
+}
+
+func TestTrie_add(t *testing.T) {
+	tr := newTrie()
+	for _, word := range []string{"propan", "prop", "proper", "proper"} {
+		tr.insert(word)
+	}
+
+	tr.add("proper", -1)
+	assert.Equal(t, []Completion{{"prop", 1}, {"propan", 1}, {"proper", 1}}, tr.top("", 10, false))
+
+	tr.add("prop", -1)
+	assert.Equal(t, []string{"propan", "proper"}, tr.withPrefix(""))
+	assert.Equal(t, []string{"propan"}, tr.fuzzy("", "porpan", 1))
+
+	// Removing a missing word changes nothing
+	tr.add("pro", -1)
+	tr.add("beer", -1)
+	assert.Equal(t, []string{"propan", "proper"}, tr.withPrefix("pro"))
+
+	tr.add("propan", -1)
+	tr.add("proper", -1)
+	assert.Equal(t, []string{}, tr.withPrefix(""))
+	assert.Equal(t, int64(0), tr.root.best)
+	assert.Equal(t, 0, len(tr.root.children))
+}
+
+func TestTrie_set(t *testing.T) {
+	tr := newTrie()
+	tr.insert("propan")
+	tr.insert("prop")
+
+	tr.set("propan", 3)
+	tr.set("proper", 2)
+	tr.set("prop", 1)
+	assert.Equal(t, []Completion{{"propan", 3}, {"proper", 2}, {"prop", 1}}, tr.top("", 10, false))
+
+	tr.set("propan", 0)
+	tr.set("pro", 0)
+	assert.Equal(t, []Completion{{"proper", 2}, {"prop", 1}}, tr.top("", 10, false))
+}
+
+func TestTrie_fuzzy(t *testing.T) {
+	tr := newTrie()
+	for _, word := range []string{"rutherford", "rutherfordton", "ruth", "further", "title:rutherford", "café", "cafe"} {
+		tr.insert(word)
+	}
+
+	assert.Equal(t, []string{"rutherford"}, tr.fuzzy("", "rutherfrod", 1))
+	assert.Equal(t, []string{"rutherford"}, tr.fuzzy("", "rutherfrod", 2))
+	assert.Equal(t, []string{}, tr.fuzzy("", "rutherfrod", 0))
+	assert.Equal(t, []string{"title:rutherford"}, tr.fuzzy("title:", "rutherfrod", 1))
+	assert.Equal(t, []string{"cafe", "café"}, tr.fuzzy("", "cafe", 1))
+	assert.Equal(t, []string{}, tr.fuzzy("beer:", "cafe", 1))
+}
+
+func TestTrie_fuzzyMatchesEditDistance(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	words := map[string]bool{}
+	tr := newTrie()
+	for _, word := range Tokenize(string(data)) {
+		word = FoldCase(word)
+		words[word] = true
+		tr.insert(word)
+	}
+
+	for _, query := range []string{"tehre", "wrold", "sience", "a", "computr", "langauge"} {
+		for distance := 0; distance <= 2; distance++ {
+			expected := []string{}
+			for word := range words {
+				if editDistance(query, word) <= distance {
+					expected = append(expected, word)
+				}
+			}
+			sort.Strings(expected)
+
+			assert.Equal(t, expected, tr.fuzzy("", query, distance), query)
+		}
+	}
+}
+
+func TestTrie_topMatchesCounts(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int64{}
+	tr := newTrie()
+	for _, word := range Tokenize(string(data)) {
+		word = FoldCase(word)
+		counts[word]++
+		tr.insert(word)
+	}
+
+	// Remove every other word once, dropping those only seen once
+	i := 0
+	for word := range counts {
+		if i++; i%2 == 0 {
+			counts[word]--
+			tr.add(word, -1)
+		}
+	}
+
+	for _, prefix := range []string{"", "t", "th", "com", "sci", "zzz"} {
+		expected := []Completion{}
+		for word, count := range counts {
+			if count > 0 && strings.HasPrefix(word, prefix) {
+				expected = append(expected, Completion{word, count})
+			}
+		}
+		sort.Sort(byCompletion(expected))
+		if len(expected) > 5 {
+			expected = expected[:5]
+		}
+
+		assert.Equal(t, expected, tr.top(prefix, 5, false), prefix)
+	}
+}
+
+// byCompletion orders completions most frequent first and then by word.
+type byCompletion []Completion
+
+func (b byCompletion) Len() int      { return len(b) }
+func (b byCompletion) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byCompletion) Less(i, j int) bool {
+	if b[i].Frequency != b[j].Frequency {
+		return b[i].Frequency > b[j].Frequency
+	}
+	return b[i].Word < b[j].Word
+}
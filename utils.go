@@ -2,9 +2,9 @@ package wally
 
 import (
 	"strconv"
-	"strings"
+	"unicode"
+	"unicode/utf8"
 
-	rdb "github.com/dancannon/gorethink"
 	"github.com/fatih/color"
 )
 
@@ -19,20 +19,10 @@ var (
 	Std = color.New(color.FgMagenta)
 )
 
-// DatabaseRebuild resets the database to an empty state, it also sets the
-// secondary index for the index table.
-func DatabaseRebuild(session *rdb.Session) {
-	rdb.DB(Conf.Database.Name).TableCreate(Conf.Tables.DocumentTable).Exec(session)
-	rdb.DB(Conf.Database.Name).TableCreate(Conf.Tables.IndexTable).Exec(session)
-	rdb.DB(Conf.Database.Name).Table(Conf.Tables.IndexTable).IndexCreate("word").Exec(session)
-	rdb.DB(Conf.Database.Name).Table(Conf.Tables.DocumentTable).Delete(rdb.DeleteOpts{
-		Durability:    "soft",
-		ReturnChanges: false,
-	}).Exec(session)
-	rdb.DB(Conf.Database.Name).Table(Conf.Tables.IndexTable).Delete(rdb.DeleteOpts{
-		Durability:    "soft",
-		ReturnChanges: false,
-	}).Exec(session)
+// DatabaseRebuild resets the store to an empty state, for RethinkDB it also
+// creates the tables and the secondary index for the index table.
+func DatabaseRebuild(store Store) error {
+	return store.Rebuild()
 }
 
 // ToString converts an interface{} to a string, a string, byte slice or integer
@@ -50,15 +40,37 @@ func ToString(v interface{}) string {
 	}
 }
 
-// TruncateText returns a truncated version of a string with a suffix appended.
+// TruncateText returns a truncated version of a string with a suffix appended,
+// text longer than upperBound bytes is cut after the last whole word that fits
+// along with any punctuation straight after it. Text without a word that fits
+// is cut at upperBound, without splitting a character.
 func TruncateText(text, suffix string, upperBound int) string {
-	if len(text) > upperBound {
-		truncated := text[:upperBound]
-		nextSpace := strings.LastIndex(truncated, " ")
-		if nextSpace > 0 {
-			truncated = text[:nextSpace]
+	if len(text) <= upperBound {
+		return text
+	}
+
+	end := 0
+	for _, span := range tokenSpans(text) {
+		if span.End > upperBound {
+			break
+		}
+		end = span.End
+	}
+
+	if end == 0 {
+		end = upperBound
+		for end > 0 && !utf8.RuneStart(text[end]) {
+			end--
 		}
-		return truncated + suffix
 	}
-	return text
+
+	for end < upperBound {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if !unicode.IsPunct(r) || end+size > upperBound {
+			break
+		}
+		end += size
+	}
+
+	return text[:end] + suffix
 }
@@ -3,25 +3,18 @@ package wally
 import (
 	"testing"
 
-	rdb "github.com/dancannon/gorethink"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestUtils_DatabaseRebuild(t *testing.T) {
-	DatabaseRebuild(session)
+	doc := Document{ID: "http://example.com"}
+	assert.NoError(t, doc.Put(store))
 
-	res, err := rdb.DB(Conf.Database.Name).TableList().Run(session)
-	if err != nil {
-		t.Errorf(err.Error())
-	}
-
-	var response []interface{}
-	err = res.All(&response)
-	if err != nil {
-		t.Errorf(err.Error())
-	}
+	err := DatabaseRebuild(store)
+	assert.NoError(t, err)
 
-	assert.Equal(t, len(response), 2)
+	_, err = store.GetDocument(doc.ID)
+	assert.Equal(t, err, ErrDocumentNotFound)
 }
 
 func TestUtils_ToString(t *testing.T) {
@@ -65,6 +58,18 @@ func TestUtils_TruncateText(t *testing.T) {
 			"Lorem ipsum dolor sit amet, natoque quis",
 			"Lorem ipsum dolor sit ...",
 		},
+		{
+			"Lorem ipsum dolor, sit—amet natoque quis",
+			"Lorem ipsum dolor, sit— ...",
+		},
+		{
+			"Loremipsumdolorsitametnatoquequis",
+			"Loremipsumdolorsitametnat ...",
+		},
+		{
+			"Ééééééééééééééééééééééé",
+			"Éééééééééééé ...",
+		},
 	}
 
 	for _, test := range tests {
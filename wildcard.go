@@ -0,0 +1,151 @@
+package wally
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// wildcard is a word of a query containing * or ?, * matches any number of
+// characters and ? matches exactly one. Before searching it's expanded into the
+// indexed terms matching any of its Patterns, which become the Terms it's
+// searched for as.
+type wildcard struct {
+	term
+	Patterns []string
+}
+
+// isWildcard reports whether a word of a query is a wildcard pattern.
+func isWildcard(text string) bool {
+	return strings.ContainsAny(text, "*?")
+}
+
+// wildcardNode returns the matcher for a wildcard pattern in field. Indexed
+// terms are case folded and usually stemmed, so a pattern is matched as it's
+// written, case folded, and stemmed in each query language, which lets te?as
+// match "texa", the stem of "Texas".
+func wildcardNode(text, field string) node {
+	pattern := FoldCase(text)
+
+	w := &wildcard{term: term{Text: pattern, Field: field}}
+	add := func(p string) {
+		if config().Analysis.FoldDiacritics {
+			p = FoldDiacritics(p)
+		}
+		if !containsString(w.Patterns, p) {
+			w.Patterns = append(w.Patterns, p)
+		}
+	}
+
+	add(pattern)
+	if config().Analysis.Stemming {
+		// A trailing * is kept off the stemmer so that it stems the word
+		// being completed
+		literal := strings.TrimRight(pattern, "*")
+		for _, language := range queryLanguages() {
+			a := LookupAnalyzer(language)
+			if a.Stemmer == nil {
+				continue
+			}
+			if stem := a.Stemmer(literal); stem != "" {
+				add(stem + pattern[len(literal):])
+			}
+		}
+	}
+	return w
+}
+
+// expand looks up the indexed terms matching the wildcard's patterns, at most
+// Expansion.MaxTerms of them, shortest first, are searched for.
+func (w *wildcard) expand(store Store) error {
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, pattern := range w.Patterns {
+		if literalPrefix(pattern) == "" {
+			continue
+		}
+		for _, field := range termFields(w.Field) {
+			name := fieldTerm(field, "")
+			words, err := store.Words(name + literalPrefix(pattern))
+			if err != nil {
+				return err
+			}
+
+			for _, word := range words {
+				// Words from other fields start with the field's name
+				// and a colon
+				t := strings.TrimPrefix(word, name)
+				if strings.Contains(t, ":") {
+					continue
+				}
+				if !seen[t] && wildcardMatch(pattern, t) {
+					seen[t] = true
+					terms = append(terms, t)
+				}
+			}
+		}
+	}
+
+	sort.Sort(byLength(terms))
+	if max := config().Expansion.MaxTerms; max > 0 && len(terms) > max {
+		terms = terms[:max]
+	}
+	w.Terms = terms
+	return nil
+}
+
+// literalPrefix returns the part of a pattern before its first wildcard.
+func literalPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, "*?"); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// wildcardMatch reports whether s matches pattern, * matches any run of
+// characters, including none, and ? matches a single character.
+func wildcardMatch(pattern, s string) bool {
+	// When a * fails to match, it's retried taking one more character of s
+	star, retry := -1, 0
+
+	p, i := 0, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, retry = p, i
+			p++
+			continue
+		case p < len(pattern) && pattern[p] == '?':
+			_, size := utf8.DecodeRuneInString(s[i:])
+			p, i = p+1, i+size
+			continue
+		case p < len(pattern) && pattern[p] == s[i]:
+			p, i = p+1, i+1
+			continue
+		}
+
+		if star < 0 {
+			return false
+		}
+		_, size := utf8.DecodeRuneInString(s[retry:])
+		retry += size
+		p, i = star+1, retry
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// byLength orders terms by their length and then alphabetically.
+type byLength []string
+
+func (b byLength) Len() int      { return len(b) }
+func (b byLength) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byLength) Less(i, j int) bool {
+	if len(b[i]) != len(b[j]) {
+		return len(b[i]) < len(b[j])
+	}
+	return b[i] < b[j]
+}
@@ -0,0 +1,73 @@
+package wally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWildcard_wildcardMatch(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Word    string
+		Match   bool
+	}{
+		{"propan*", "propan", true},
+		{"propan*", "propanol", true},
+		{"propan*", "prop", false},
+		{"te?as", "texas", true},
+		{"te?as", "teas", false},
+		{"te?a", "texa", true},
+		{"te*as", "teas", true},
+		{"te*as", "tea cosas", true},
+		{"te*as", "texan", false},
+		{"c?fé", "café", true},
+		{"caf?", "café", true},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+		{"**", "", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Match, wildcardMatch(test.Pattern, test.Word), test.Pattern+" "+test.Word)
+	}
+}
+
+func TestWildcard_wildcardNode(t *testing.T) {
+	w := wildcardNode("Te?as", "title").(*wildcard)
+	assert.Equal(t, "te?as", w.Text)
+	assert.Equal(t, "title", w.Field)
+	assert.Equal(t, []string{"te?as", "te?a"}, w.Patterns)
+
+	w = wildcardNode("houses*", "").(*wildcard)
+	assert.Equal(t, []string{"houses*", "hous*"}, w.Patterns)
+
+	Conf.Analysis.Stemming = false
+	defer func() { Conf.Analysis.Stemming = true }()
+
+	w = wildcardNode("houses*", "").(*wildcard)
+	assert.Equal(t, []string{"houses*"}, w.Patterns)
+}
+
+func TestWildcard_expand(t *testing.T) {
+	defer tearDbDown(store)
+
+	doc := &Document{ID: "1", Title: "Propane", Content: "Propane, propanol, props and proper propaganda"}
+	assert.NoError(t, IndexBatchPut(store, IndexDocument(doc)))
+
+	w := wildcardNode("propa*", "").(*wildcard)
+	assert.NoError(t, w.expand(store))
+	assert.Equal(t, []string{"propan", "propanol", "propaganda"}, w.Terms)
+	assert.Equal(t, []string{"propan", "propanol", "propaganda", "title:propan", "title:propanol", "title:propaganda", "author:propan", "author:propanol", "author:propaganda"}, w.keys())
+
+	w = wildcardNode("propa*", "title").(*wildcard)
+	assert.NoError(t, w.expand(store))
+	assert.Equal(t, []string{"propan"}, w.Terms)
+
+	Conf.Expansion.MaxTerms = 2
+	defer func() { Conf.Expansion.MaxTerms = DefaultConfig().Expansion.MaxTerms }()
+
+	w = wildcardNode("pro*", "").(*wildcard)
+	assert.NoError(t, w.expand(store))
+	assert.Equal(t, []string{"prop", "propan"}, w.Terms)
+}